@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TheLazarusNetwork/Monitor/audit"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/utility"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// auditChain walks the Log events of the agents and reports breaks, reordering and gaps in the chain of every source,
+// it exits with status 1 when any issue is found
+//
+//	monitor audit [-sender 0x..,0x..] [-from 0] [-to latest] [-chunk 5000]
+func auditChain(args []string) {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	senders := flags.String("sender", "", "comma separated agent addresses, the configured agent by default")
+	from := flags.Uint64("from", 0, "first block to read, 0 reads from the deployment and expects every chain to start at 1")
+	to := flags.Uint64("to", 0, "last block to read, 0 for the latest block")
+	chunk := flags.Uint64("chunk", audit.DefaultChunk, "blocks queried per request")
+	flags.Parse(args)
+
+	addresses, err := parseSenders(*senders)
+	utility.CheckError("Error in reading senders:", err)
+	client, err := ethclient.Dial(viper.Get("INFURA_ENDPOINT").(string))
+	utility.CheckError("Error in connecting to Infura EndPoint:", err)
+	defer client.Close()
+	ctx := context.Background()
+	if *to == 0 {
+		*to, err = client.BlockNumber(ctx)
+		utility.CheckError("Error in fetching latest block:", err)
+	}
+	filterer, err := logger.NewLoggerFilterer(common.HexToAddress(viper.Get("LOGGER_CONTRACT_ADDRESS").(string)), client)
+	utility.CheckError("Unable to load instance of the deployed contract:", err)
+
+	verifier := audit.NewVerifier(*from == 0)
+	events := 0
+	err = audit.Walk(ctx, filterer, addresses, *from, *to, *chunk, func(event *logger.LoggerLog) error {
+		verifier.Add(event)
+		events++
+		return nil
+	})
+	utility.CheckError("Error in reading Log events:", err)
+
+	issues := verifier.Issues()
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	log.Infof("Audited %d events of blocks %d to %d: %d issues", events, *from, *to, len(issues))
+	if len(issues) > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseSenders reads comma separated addresses, falling back to the address of the configured agent
+func parseSenders(value string) ([]common.Address, error) {
+	var addresses []common.Address
+	for _, sender := range strings.Split(value, ",") {
+		sender = strings.TrimSpace(sender)
+		if sender == "" {
+			continue
+		}
+		if !common.IsHexAddress(sender) {
+			return nil, fmt.Errorf("invalid address %q", sender)
+		}
+		addresses = append(addresses, common.HexToAddress(sender))
+	}
+	if len(addresses) > 0 {
+		return addresses, nil
+	}
+	signer, _, err := newSigner()
+	if err != nil {
+		return nil, err
+	}
+	return []common.Address{signer.Address()}, nil
+}
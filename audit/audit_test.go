@@ -0,0 +1,399 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/envelope"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/merkle"
+	"github.com/TheLazarusNetwork/Monitor/model"
+	"github.com/TheLazarusNetwork/Monitor/transactor"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+// TestVerifier Gaps, reordering, broken links and foreign signatures are reported per source
+func TestVerifier(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	agent := crypto.PubkeyToAddress(key.PublicKey)
+	sequencer, err := batch.LoadSequencer(filepath.Join(t.TempDir(), "sequence.json"))
+	if err != nil {
+		t.Fatalf("Failed to load sequencer: %v", err)
+	}
+	var batches []*batch.Batch
+	for i := 0; i < 5; i++ {
+		b, err := batch.New([]model.Line{{Source: "nginx", Text: "line", Position: uint64(i + 1)}})
+		if err != nil {
+			t.Fatalf("Failed to build batch: %v", err)
+		}
+		if err := sequencer.Chain(b); err != nil {
+			t.Fatalf("Failed to chain batch: %v", err)
+		}
+		if err := b.Sign(transactor.NewKeySigner(key)); err != nil {
+			t.Fatalf("Failed to sign batch: %v", err)
+		}
+		batches = append(batches, b)
+	}
+	block := uint64(0)
+	event := func(b *batch.Batch) *logger.LoggerLog {
+		data, err := b.Anchor().Encode()
+		if err != nil {
+			t.Fatalf("Failed to encode anchor: %v", err)
+		}
+		block++
+		return &logger.LoggerLog{Sender: agent, Data: data, Raw: types.Log{BlockNumber: block}}
+	}
+	verify := func(genesis bool, logged ...*batch.Batch) []Issue {
+		verifier := NewVerifier(genesis)
+		for _, b := range logged {
+			verifier.Add(event(b))
+		}
+		return verifier.Issues()
+	}
+
+	if issues := verify(true, batches...); len(issues) != 0 {
+		t.Errorf("Expected an intact chain, received %v", issues)
+	}
+	issues := verify(false, batches[0], batches[1], batches[3], batches[2])
+	if len(issues) != 1 || issues[0].Kind != KindReordered || issues[0].Sequence != 3 {
+		t.Errorf("Expected #3 to be reported as reordered, received %v", issues)
+	}
+	issues = verify(true, batches[2], batches[4])
+	if len(issues) != 3 || issues[0].Kind != KindMissing || issues[0].Sequence != 1 || issues[1].Sequence != 2 || issues[2].Sequence != 4 {
+		t.Errorf("Expected #1, #2 and #4 to be missing, received %v", issues)
+	}
+
+	forged := *batches[2]
+	forged.Previous = common.Hash{1}
+	if err := forged.Sign(transactor.NewKeySigner(key)); err != nil {
+		t.Fatalf("Failed to sign batch: %v", err)
+	}
+	issues = verify(false, batches[0], batches[1], &forged)
+	if len(issues) != 1 || issues[0].Kind != KindBreak || issues[0].Sequence != 3 {
+		t.Errorf("Expected #3 to break the chain, received %v", issues)
+	}
+
+	other, _ := crypto.GenerateKey()
+	if err := forged.Sign(transactor.NewKeySigner(other)); err != nil {
+		t.Fatalf("Failed to sign batch: %v", err)
+	}
+	issues = verify(false, &forged)
+	if len(issues) != 1 || issues[0].Kind != KindSignature {
+		t.Errorf("Expected a foreign signature to be reported, received %v", issues)
+	}
+}
+
+// TestDecoder Inline payloads decrypt to the anchored lines, tampered lines and foreign keys are rejected, baseline entries open with a legacy key
+func TestDecoder(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	recipient := ecies.ImportECDSA(key)
+	seal := func(lines []model.Line) []byte {
+		plaintext, err := json.Marshal(lines)
+		if err != nil {
+			t.Fatalf("Failed to encode lines: %v", err)
+		}
+		payload, err := envelope.Seal(plaintext, 0, []*ecies.PublicKey{&recipient.PublicKey})
+		if err != nil {
+			t.Fatalf("Failed to seal lines: %v", err)
+		}
+		return payload
+	}
+	lines := []model.Line{{Source: "nginx", Text: "GET /"}, {Source: "nginx", Text: "GET /admin"}}
+	b, err := batch.New(lines)
+	if err != nil {
+		t.Fatalf("Failed to build batch: %v", err)
+	}
+	b.Payload = seal(lines)
+	data, err := b.Anchor().Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode anchor: %v", err)
+	}
+
+	decoder := &Decoder{Keys: envelope.KeysOf(recipient)}
+	entry := decoder.Decode(&logger.LoggerLog{Data: data, Raw: types.Log{BlockNumber: 7}})
+	if entry.Err != nil || entry.Block != 7 || len(entry.Lines) != 2 || entry.Lines[1].Text != "GET /admin" {
+		t.Errorf("Expected both lines to decrypt, received %+v", entry)
+	}
+
+	b.Payload = seal([]model.Line{lines[0], {Source: "nginx", Text: "GET /"}})
+	if _, err := decoder.Open(b.Anchor()); err != ErrRootMismatch {
+		t.Errorf("Expected tampered lines to be rejected, received %v", err)
+	}
+	other, _ := crypto.GenerateKey()
+	decoder.Keys = envelope.KeysOf(ecies.ImportECDSA(other))
+	if _, err := decoder.Open(b.Anchor()); err != envelope.ErrNotRecipient {
+		t.Errorf("Expected a foreign key to be rejected, received %v", err)
+	}
+
+	// Baseline entries logged every line as hex encoded ECIES under the wallet key
+	ciphertext, err := ecies.Encrypt(rand.Reader, &recipient.PublicKey, []byte("GET /legacy"), nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt line: %v", err)
+	}
+	legacy := &logger.LoggerLog{Data: hex.EncodeToString(ciphertext), Raw: types.Log{BlockNumber: 3}}
+	if entry := decoder.Decode(legacy); entry.Err != ErrNoLegacyKey {
+		t.Errorf("Expected a baseline entry to need a legacy key, received %+v", entry)
+	}
+	decoder.Legacy = []*ecies.PrivateKey{ecies.ImportECDSA(other), recipient}
+	if entry := decoder.Decode(legacy); entry.Err != nil || entry.Anchor != nil || len(entry.Lines) != 1 || entry.Lines[0].Text != "GET /legacy" {
+		t.Errorf("Expected the baseline line to decrypt, received %+v", entry)
+	}
+}
+
+// TestFollower Past events are backfilled before new ones stream in, each delivered once, also after a temporary failure
+func TestFollower(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(1e18)}}, 8000000)
+	defer backend.Close()
+	address, _, contract, err := logger.DeployLogger(auth, backend)
+	if err != nil {
+		t.Fatalf("Failed to deploy the Logger contract: %v", err)
+	}
+	backend.Commit()
+	for _, data := range []string{"first", "second"} {
+		if _, err := contract.DataLog(auth, data); err != nil {
+			t.Fatalf("Failed to log %s: %v", data, err)
+		}
+		backend.Commit()
+	}
+
+	follower, err := NewFollower(backend, address, []common.Address{auth.From}, 1)
+	if err != nil {
+		t.Fatalf("Failed to create follower: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan string, 10)
+	failed := false
+	go follower.Run(ctx, 1, func(event *logger.LoggerLog) error {
+		// A temporary failure delivers the event again after resubscribing
+		if event.Data == "second" && !failed {
+			failed = true
+			return Retry(errors.New("node unavailable"))
+		}
+		received <- event.Data
+		return nil
+	})
+	next := func() string {
+		select {
+		case data := <-received:
+			return data
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for an event")
+			return ""
+		}
+	}
+	if first, second := next(), next(); first != "first" || second != "second" {
+		t.Errorf("Expected the past events in order, received %q and %q", first, second)
+	}
+
+	if _, err := contract.DataLog(auth, "third"); err != nil {
+		t.Fatalf("Failed to log third: %v", err)
+	}
+	backend.Commit()
+	if third := next(); third != "third" {
+		t.Errorf("Expected the new event, received %q", third)
+	}
+	select {
+	case data := <-received:
+		t.Errorf("Expected every event once, received %q again", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestReconciler Local lines are aligned with the anchored lines, modified and missing lines are reported on both sides
+func TestReconciler(t *testing.T) {
+	anchored := []model.Line{
+		{File: "access.log", Text: "one", Offset: 4, Inode: 9},
+		{File: "access.log", Text: "two", Offset: 8, Inode: 9},
+		{File: "access.log", Text: "three", Offset: 14, Inode: 9},
+		{File: "access.log", Text: "four", Offset: 19, Inode: 9},
+		{File: "error.log", Text: "other", Offset: 6, Inode: 9},
+	}
+	reconciler := NewReconciler("access.log", 0)
+	reconciler.Add(&Entry{Block: 10, Lines: anchored})
+
+	// two was edited, three deleted and six appended after the last anchored line
+	local, err := ReadLines(strings.NewReader("one\nTWO\nfour\nsix"), func(text string) (string, error) { return text, nil })
+	if err != nil {
+		t.Fatalf("Failed to read lines: %v", err)
+	}
+	report := reconciler.Reconcile(local, 9)
+	statuses := map[string]Status{}
+	for _, result := range report.Results {
+		statuses[result.Local+result.Anchored] = result.Status
+	}
+	if statuses["oneone"] != StatusMatched || statuses["TWOtwo"] != StatusModified || statuses["three"] != StatusMissingLocally || statuses["six"] != StatusPending {
+		t.Errorf("Expected one matched, two modified, three missing locally and six pending, received %+v", report.Results)
+	}
+	// four moved up but still follows the anchored lines
+	if statuses["fourfour"] != StatusMatched || report.Counts[StatusMatched] != 2 || report.Clean(false) {
+		t.Errorf("Expected four to match after the removed line, received %+v", report.Counts)
+	}
+
+	clean := NewReconciler("access.log", 0)
+	clean.Add(&Entry{Lines: anchored[:2]})
+	clean.AddProven([]common.Hash{merkle.LeafHash([]byte("three"))}, 11, common.Hash{})
+	local, _ = ReadLines(strings.NewReader("one\ntwo\nthree\n"), func(text string) (string, error) { return text, nil })
+	if report := clean.Reconcile(local, 1); !report.Clean(true) {
+		t.Errorf("Expected every line to match in place or by hash, received %+v", report.Results)
+	}
+	// Generations of a rotated file restart at offset zero, a copy holding both still matches every line
+	rotated := NewReconciler("access.log", 0)
+	rotated.Add(&Entry{Lines: append(anchored[:2:2], model.Line{File: "access.log", Text: "uno", Offset: 4, Inode: 10}, model.Line{File: "access.log", Text: "dos", Offset: 8, Inode: 10})})
+	local, _ = ReadLines(strings.NewReader("one\ntwo\nuno\ndos\n"), func(text string) (string, error) { return text, nil })
+	if report := rotated.Reconcile(local, 1); !report.Clean(true) || report.Counts[StatusMatched] != 4 {
+		t.Errorf("Expected the lines of both generations to match, received %+v", report.Results)
+	}
+	// A carriage return stays part of the line, as the tailer anchored it
+	local, _ = ReadLines(strings.NewReader("one\r\n"), func(text string) (string, error) { return text, nil })
+	if len(local) != 1 || local[0].Text != "one\r" || local[0].Offset != 5 {
+		t.Errorf("Expected the carriage return to be kept, received %+v", local)
+	}
+}
+
+// TestBundle A bundle verifies offline from the line up to the block header, tampered bundles are rejected
+func TestBundle(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth, _ := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(1e18)}}, 8000000)
+	defer backend.Close()
+	address, _, contract, err := logger.DeployLogger(auth, backend)
+	if err != nil {
+		t.Fatalf("Failed to deploy the Logger contract: %v", err)
+	}
+	backend.Commit()
+
+	lines := []model.Line{{Source: "nginx", Text: "GET /"}, {Source: "nginx", Text: "GET /admin"}, {Source: "nginx", Text: "POST /login"}}
+	b, err := batch.New(lines)
+	if err != nil {
+		t.Fatalf("Failed to build batch: %v", err)
+	}
+	b.Sequence = 1
+	if err := b.Sign(transactor.NewKeySigner(key)); err != nil {
+		t.Fatalf("Failed to sign batch: %v", err)
+	}
+	plaintext, _ := json.Marshal(lines)
+	recipient := ecies.ImportECDSA(key)
+	if b.Payload, err = envelope.Seal(plaintext, 0, []*ecies.PublicKey{&recipient.PublicKey}); err != nil {
+		t.Fatalf("Failed to seal lines: %v", err)
+	}
+	data, err := b.Anchor().Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode anchor: %v", err)
+	}
+	tx, err := contract.DataLog(auth, data)
+	if err != nil {
+		t.Fatalf("Failed to log anchor: %v", err)
+	}
+	backend.Commit()
+
+	evidence, err := Collect(context.Background(), backend, address, tx.Hash(), 0, &Decoder{Keys: envelope.KeysOf(recipient)})
+	if err != nil {
+		t.Fatalf("Failed to collect evidence: %v", err)
+	}
+	bundle, err := evidence.Bundle(1)
+	if err != nil {
+		t.Fatalf("Failed to build bundle: %v", err)
+	}
+	encoded, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatalf("Failed to encode bundle: %v", err)
+	}
+	load := func() *Bundle {
+		decoded := &Bundle{}
+		if err := json.Unmarshal(encoded, decoded); err != nil {
+			t.Fatalf("Failed to decode bundle: %v", err)
+		}
+		return decoded
+	}
+
+	proven, err := VerifyBundle(load(), address)
+	if err != nil {
+		t.Fatalf("Expected the bundle to verify: %v", err)
+	}
+	if proven.Line.Text != "GET /admin" || proven.Sender != auth.From || !proven.Signed || proven.Sequence != 1 || proven.BlockHash != bundle.Header.Hash() {
+		t.Errorf("Expected the line, agent and block to be proven, received %+v", proven)
+	}
+
+	tampered := load()
+	tampered.Line.Text = "GET /"
+	if _, err := VerifyBundle(tampered, address); err == nil {
+		t.Error("Expected a changed line to be rejected")
+	}
+	tampered = load()
+	tampered.Receipt.CumulativeGasUsed++
+	if _, err := VerifyBundle(tampered, address); err == nil {
+		t.Error("Expected a changed receipt to be rejected")
+	}
+	tampered = load()
+	tampered.Header.Time++
+	if _, err := VerifyBundle(tampered, address); err == nil {
+		t.Error("Expected a changed header to be rejected")
+	}
+	if _, err := VerifyBundle(load(), common.Address{1}); err == nil {
+		t.Error("Expected an event of another contract to be rejected")
+	}
+
+	// Later forks cannot be reproduced with the pinned types and are rejected as such, not as forgeries
+	tampered = load()
+	tampered.Receipt.Type = 3
+	if _, err := VerifyBundle(tampered, address); err != ErrUnsupportedReceipt {
+		t.Errorf("Expected a blob receipt to be unsupported, received %v", err)
+	}
+	later := strings.Replace(string(encoded), `"header":{`, `"header":{"withdrawalsRoot":"0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",`, 1)
+	if err := json.Unmarshal([]byte(later), &Bundle{}); err != ErrUnsupportedBlock {
+		t.Errorf("Expected a post-London header to be unsupported, received %v", err)
+	}
+
+	// Baseline entries hold no root to prove a line against, they are refused on export and on verification
+	ciphertext, err := ecies.Encrypt(rand.Reader, &recipient.PublicKey, []byte("GET /legacy"), nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt line: %v", err)
+	}
+	tx, err = contract.DataLog(auth, hex.EncodeToString(ciphertext))
+	if err != nil {
+		t.Fatalf("Failed to log baseline entry: %v", err)
+	}
+	backend.Commit()
+	decoder := &Decoder{Keys: envelope.KeysOf(recipient), Legacy: []*ecies.PrivateKey{recipient}}
+	if _, err := Collect(context.Background(), backend, address, tx.Hash(), 0, decoder); err != ErrLegacyEntry {
+		t.Errorf("Expected a baseline entry to be refused on export, received %v", err)
+	}
+	receipt, err := backend.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("Failed to read receipt: %v", err)
+	}
+	block, err := backend.BlockByHash(context.Background(), receipt.BlockHash)
+	if err != nil {
+		t.Fatalf("Failed to read block: %v", err)
+	}
+	proof, err := proveReceipt(types.Receipts{receipt}, receipt.TransactionIndex, block.ReceiptHash())
+	if err != nil {
+		t.Fatalf("Failed to prove receipt: %v", err)
+	}
+	legacy := &Evidence{Entry: &Entry{Lines: []model.Line{{Text: "GET /legacy"}}}, Event: *receipt.Logs[0], Receipt: receipt, ReceiptProof: proof, Header: block.Header()}
+	if bundle, err = legacy.Bundle(0); err != nil {
+		t.Fatalf("Failed to build bundle: %v", err)
+	}
+	if _, err := VerifyBundle(bundle, address); err != ErrLegacyEntry {
+		t.Errorf("Expected a baseline bundle to be refused, received %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/merkle"
+	"github.com/TheLazarusNetwork/Monitor/model"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// BundleVersion current version of the proof bundle format
+const BundleVersion = 1
+
+// ErrUnsupportedBlock the block has header fields added after London, whose hash the pinned go-ethereum types cannot reproduce
+var ErrUnsupportedBlock = errors.New("bundle: blocks with header fields newer than London are not supported")
+
+// ErrUnsupportedReceipt the receipt is of a transaction type newer than EIP-1559, whose encoding the pinned go-ethereum types cannot reproduce
+var ErrUnsupportedReceipt = errors.New("bundle: receipts of transaction types newer than EIP-1559 are not supported")
+
+// ErrLegacyEntry baseline entries log a single encrypted line without a Merkle root, nothing in them proves the line to whoever checks a bundle
+var ErrLegacyEntry = errors.New("bundle: baseline entries carry no anchored root and cannot be proven by a bundle")
+
+// laterHeaderFields header fields of forks after London, present in the JSON of blocks the bundle cannot prove
+var laterHeaderFields = []string{"withdrawalsRoot", "blobGasUsed", "excessBlobGas", "parentBeaconBlockRoot", "requestsHash"}
+
+// Bundle self-contained evidence that a log line was anchored through the Logger contract in a block,
+// checked by VerifyBundle without access to a node
+//
+// The line is proven against the anchored Merkle root, the anchor is the data of the Log event, the event
+// is part of the receipt and the receipt is proven against the receipt root of the block header. Whoever
+// checks the bundle compares the header hash with a source of the chain they trust
+type Bundle struct {
+	Version      int             `json:"version"`
+	Line         model.Line      `json:"line"`
+	Index        int             `json:"index"` // Position of the line in its batch
+	Proof        merkle.Proof    `json:"proof"` // Inclusion of the line in the anchored root
+	Event        types.Log       `json:"event"`
+	Receipt      *types.Receipt  `json:"receipt"`
+	ReceiptProof []hexutil.Bytes `json:"receiptProof"` // Trie nodes from the receipt root down to the receipt
+	Header       *types.Header   `json:"header"`
+}
+
+// UnmarshalJSON decodes a bundle, rejecting headers with fields the header type would silently drop
+func (bundle *Bundle) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Header map[string]json.RawMessage `json:"header"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for _, field := range laterHeaderFields {
+		if _, ok := raw.Header[field]; ok {
+			return ErrUnsupportedBlock
+		}
+	}
+	type plain Bundle
+	return json.Unmarshal(data, (*plain)(bundle))
+}
+
+// ChainReader reads the blocks and receipts a bundle is built from, such as ethclient.Client
+type ChainReader interface {
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Evidence the chain data behind one Log event, shared by the bundles of every line of its batch
+type Evidence struct {
+	Entry        *Entry
+	Event        types.Log
+	Receipt      *types.Receipt
+	ReceiptProof []hexutil.Bytes
+	Header       *types.Header
+}
+
+// Collect reads the Log event of the contract in the transaction, the n-th one when it logged several,
+// decrypts its batch and proves its receipt against the block
+func Collect(ctx context.Context, chain ChainReader, contract common.Address, txHash common.Hash, n int, decoder *Decoder) (*Evidence, error) {
+	receipt, err := chain.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Type > types.DynamicFeeTxType {
+		return nil, ErrUnsupportedReceipt
+	}
+	filterer, eventID, err := logFilterer(contract)
+	if err != nil {
+		return nil, err
+	}
+	var event *types.Log
+	for _, candidate := range receipt.Logs {
+		if candidate.Address == contract && len(candidate.Topics) > 0 && candidate.Topics[0] == eventID {
+			if n--; n < 0 {
+				event = candidate
+				break
+			}
+		}
+	}
+	if event == nil {
+		return nil, fmt.Errorf("no such Log event of %s in tx %s", contract.Hex(), txHash.Hex())
+	}
+	parsed, err := filterer.ParseLog(*event)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := LegacyCiphertext(parsed.Data); ok {
+		return nil, ErrLegacyEntry
+	}
+	entry := decoder.Decode(parsed)
+	if entry.Err != nil {
+		return nil, entry.Err
+	}
+
+	block, err := chain.BlockByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("block %s: %v", receipt.BlockHash.Hex(), err)
+	}
+	if block.Hash() != receipt.BlockHash {
+		return nil, ErrUnsupportedBlock
+	}
+	receipts := make(types.Receipts, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		if receipts[i], err = chain.TransactionReceipt(ctx, tx.Hash()); err != nil {
+			return nil, err
+		}
+		if receipts[i].Type > types.DynamicFeeTxType {
+			return nil, ErrUnsupportedReceipt
+		}
+	}
+	proof, err := proveReceipt(receipts, receipt.TransactionIndex, block.ReceiptHash())
+	if err != nil {
+		return nil, err
+	}
+	entry.Time = time.Unix(int64(block.Time()), 0).UTC()
+	return &Evidence{Entry: entry, Event: *event, Receipt: receipt, ReceiptProof: proof, Header: block.Header()}, nil
+}
+
+// Bundle the bundle of the line at index in the batch
+func (evidence *Evidence) Bundle(index int) (*Bundle, error) {
+	lines := evidence.Entry.Lines
+	if index < 0 || index >= len(lines) {
+		return nil, fmt.Errorf("line %d out of the %d lines of the batch", index, len(lines))
+	}
+	leaves := make([][]byte, len(lines))
+	for i, line := range lines {
+		leaves[i] = []byte(line.Text)
+	}
+	tree, err := merkle.New(leaves)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{
+		Version:      BundleVersion,
+		Line:         lines[index],
+		Index:        index,
+		Proof:        tree.Proof(index),
+		Event:        evidence.Event,
+		Receipt:      evidence.Receipt,
+		ReceiptProof: evidence.ReceiptProof,
+		Header:       evidence.Header,
+	}, nil
+}
+
+// Proven what a verified bundle proves
+type Proven struct {
+	Line      model.Line // Only the text of the line is committed to by the anchored root
+	Source    string
+	Sequence  uint64
+	Sender    common.Address // Agent that logged the anchor and, from version 3 anchors on, signed its statement
+	Contract  common.Address
+	Block     uint64
+	BlockHash common.Hash // To be compared with a trusted source of the chain
+	Time      time.Time   // Timestamp of the block, the line existed by then
+	Signed    bool        // Whether the anchor carries the agent's signature
+}
+
+// VerifyBundle checks every link from the line up to the block header, contract may be the zero address to accept any
+func VerifyBundle(bundle *Bundle, contract common.Address) (*Proven, error) {
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("bundle: unsupported version %d", bundle.Version)
+	}
+	if bundle.Header == nil || bundle.Receipt == nil {
+		return nil, errors.New("bundle: header or receipt missing")
+	}
+	if bundle.Receipt.Type > types.DynamicFeeTxType {
+		return nil, ErrUnsupportedReceipt
+	}
+	blockHash := bundle.Header.Hash()
+	if bundle.Receipt.BlockHash != blockHash || bundle.Event.BlockHash != blockHash || bundle.Event.BlockNumber != bundle.Header.Number.Uint64() {
+		return nil, errors.New("bundle: receipt or event is not from the block of the header")
+	}
+	if bundle.Event.TxHash != bundle.Receipt.TxHash {
+		return nil, errors.New("bundle: event is not from the transaction of the receipt")
+	}
+
+	// The receipt, with every log it holds, is committed to by the header's receipt root
+	nodes := memorydb.New()
+	for _, node := range bundle.ReceiptProof {
+		nodes.Put(crypto.Keccak256(node), node)
+	}
+	proven, err := trie.VerifyProof(bundle.Header.ReceiptHash, rlp.AppendUint64(nil, uint64(bundle.Receipt.TransactionIndex)), nodes)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: receipt proof: %v", err)
+	}
+	var encoded bytes.Buffer
+	types.Receipts{bundle.Receipt}.EncodeIndex(0, &encoded)
+	if !bytes.Equal(proven, encoded.Bytes()) {
+		return nil, errors.New("bundle: receipt does not match the block's receipt root")
+	}
+	found := false
+	for _, log := range bundle.Receipt.Logs {
+		found = found || log.Address == bundle.Event.Address && bytes.Equal(log.Data, bundle.Event.Data) && equalTopics(log.Topics, bundle.Event.Topics)
+	}
+	if !found {
+		return nil, errors.New("bundle: event is not among the logs of the receipt")
+	}
+	if contract != (common.Address{}) && bundle.Event.Address != contract {
+		return nil, fmt.Errorf("bundle: event of %s instead of the Logger contract %s", bundle.Event.Address.Hex(), contract.Hex())
+	}
+
+	// The event carries the anchor, whose root commits to the line
+	filterer, _, err := logFilterer(bundle.Event.Address)
+	if err != nil {
+		return nil, err
+	}
+	event, err := filterer.ParseLog(bundle.Event)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: event: %v", err)
+	}
+	if _, ok := LegacyCiphertext(event.Data); ok {
+		return nil, ErrLegacyEntry
+	}
+	anchor, err := batch.DecodeAnchor(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %v", err)
+	}
+	if bundle.Index < 0 || bundle.Index >= anchor.Count || !merkle.Verify(anchor.Root, []byte(bundle.Line.Text), bundle.Proof) {
+		return nil, errors.New("bundle: line is not part of the anchored batch")
+	}
+	result := &Proven{
+		Line:      bundle.Line,
+		Sender:    event.Sender,
+		Contract:  bundle.Event.Address,
+		Block:     bundle.Event.BlockNumber,
+		BlockHash: blockHash,
+		Time:      time.Unix(int64(bundle.Header.Time), 0).UTC(),
+	}
+	if statement := anchor.Statement(); statement != nil {
+		if err := statement.Verify(anchor.Signature, event.Sender); err != nil {
+			return nil, fmt.Errorf("bundle: %v", err)
+		}
+		result.Source, result.Sequence, result.Signed = statement.Source, statement.Sequence, true
+	}
+	return result, nil
+}
+
+// proveReceipt rebuilds the receipt trie of a block and returns the nodes proving the receipt at index
+func proveReceipt(receipts types.Receipts, index uint, root common.Hash) ([]hexutil.Bytes, error) {
+	receiptTrie, err := trie.New(common.Hash{}, trie.NewDatabase(memorydb.New()))
+	if err != nil {
+		return nil, err
+	}
+	for i := range receipts {
+		var value bytes.Buffer
+		receipts.EncodeIndex(i, &value)
+		receiptTrie.Update(rlp.AppendUint64(nil, uint64(i)), value.Bytes())
+	}
+	if receiptTrie.Hash() != root {
+		return nil, errors.New("receipts of the block do not match its receipt root")
+	}
+	nodes := memorydb.New()
+	if err := receiptTrie.Prove(rlp.AppendUint64(nil, uint64(index)), 0, nodes); err != nil {
+		return nil, err
+	}
+	var proof []hexutil.Bytes
+	iterator := nodes.NewIterator(nil, nil)
+	defer iterator.Release()
+	for iterator.Next() {
+		proof = append(proof, common.CopyBytes(iterator.Value()))
+	}
+	return proof, nil
+}
+
+// logFilterer parses Log events of the contract without a node, along with the event's topic
+func logFilterer(contract common.Address) (*logger.LoggerFilterer, common.Hash, error) {
+	parsed, err := abi.JSON(strings.NewReader(logger.LoggerABI))
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	filterer, err := logger.NewLoggerFilterer(contract, nil)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	return filterer, parsed.Events["Log"].ID, nil
+}
+
+func equalTopics(a, b []common.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
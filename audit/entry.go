@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/compression"
+	"github.com/TheLazarusNetwork/Monitor/envelope"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/merkle"
+	"github.com/TheLazarusNetwork/Monitor/model"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+// ErrNoPayload the anchor carries no payload and its pointer cannot be read here
+var ErrNoPayload = errors.New("payload is neither inline nor readable through the pointer")
+
+// ErrRootMismatch the decrypted lines do not hash to the anchored Merkle root
+var ErrRootMismatch = errors.New("decrypted lines do not match the anchored root")
+
+// ErrNoLegacyKey none of the legacy keys opens a baseline entry
+var ErrNoLegacyKey = errors.New("baseline entry does not open with any legacy key")
+
+// legacyOverhead bytes ECIES adds to a baseline line: ephemeral public key, IV and MAC
+const legacyOverhead = 65 + 16 + 32
+
+// Entry one Log event with the batch it anchors, Lines is set when the payload could be decrypted
+type Entry struct {
+	Sender common.Address
+	Block  uint64
+	TxHash common.Hash
+	Index  uint          // Position of the event in its block
+	Time   time.Time     // Timestamp of the block
+	Anchor *batch.Anchor // Nil for baseline entries, which carry a single line and no anchor
+	Lines  []model.Line
+	Err    error // Why the anchor could not be decoded or its payload not decrypted
+}
+
+// Decoder decodes the anchors of Log events and decrypts their payloads with the keys it holds
+type Decoder struct {
+	Keys   envelope.Keys
+	Codec  *compression.Codec  // Decompresses zstd payloads sealed with a dictionary
+	Legacy []*ecies.PrivateKey // Keys tried on baseline entries, which do not name their key
+}
+
+// Decode decodes the anchor of the event and decrypts its payload, inline or behind a local file pointer
+func (decoder *Decoder) Decode(event *logger.LoggerLog) *Entry {
+	entry := &Entry{Sender: event.Sender, Block: event.Raw.BlockNumber, TxHash: event.Raw.TxHash, Index: event.Raw.Index}
+	if ciphertext, ok := LegacyCiphertext(event.Data); ok {
+		entry.Lines, entry.Err = decoder.openLegacy(ciphertext)
+		return entry
+	}
+	entry.Anchor, entry.Err = batch.DecodeAnchor(event.Data)
+	if entry.Err != nil {
+		return entry
+	}
+	entry.Lines, entry.Err = decoder.Open(entry.Anchor)
+	return entry
+}
+
+// Open decrypts the lines of an anchored batch and checks them against its root
+func (decoder *Decoder) Open(anchor *batch.Anchor) ([]model.Line, error) {
+	payload := anchor.Payload
+	if len(payload) == 0 {
+		payload = readPointer(anchor.Pointer)
+	}
+	if len(payload) == 0 {
+		return nil, ErrNoPayload
+	}
+	plaintext, err := envelope.OpenWith(payload, decoder.Keys, decoder.Codec)
+	if err != nil {
+		return nil, err
+	}
+	var lines []model.Line
+	if err := json.Unmarshal(plaintext, &lines); err != nil {
+		return nil, fmt.Errorf("decrypted payload: %v", err)
+	}
+	leaves := make([][]byte, len(lines))
+	for i, line := range lines {
+		leaves[i] = []byte(line.Text)
+	}
+	tree, err := merkle.New(leaves)
+	if err != nil || tree.Root() != anchor.Root {
+		return nil, ErrRootMismatch
+	}
+	return lines, nil
+}
+
+// LegacyCiphertext the ciphertext of a baseline entry, which logged every line on its own as hex encoded
+// ECIES. Anchors are never valid hex, JSON ones start with a brace and binary ones with a version byte
+func LegacyCiphertext(data string) ([]byte, bool) {
+	ciphertext, err := hex.DecodeString(data)
+	if err != nil || len(ciphertext) < legacyOverhead {
+		return nil, false
+	}
+	return ciphertext, true
+}
+
+// openLegacy decrypts the line of a baseline entry with the first legacy key that opens it
+func (decoder *Decoder) openLegacy(ciphertext []byte) ([]model.Line, error) {
+	for _, key := range decoder.Legacy {
+		if plaintext, err := key.Decrypt(ciphertext, nil, nil); err == nil {
+			return []model.Line{{Text: string(plaintext)}}, nil
+		}
+	}
+	return nil, ErrNoLegacyKey
+}
+
+// readPointer reads a file pointer of the local batch store, nil for anything else
+func readPointer(pointer string) []byte {
+	location, err := url.Parse(pointer)
+	if err != nil || location.Scheme != "file" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.FromSlash(location.Path))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// HeaderReader reads block headers, such as ethclient.Client
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Timestamps looks block timestamps up, caching the blocks already seen
+type Timestamps struct {
+	client HeaderReader
+	mutex  sync.Mutex
+	cache  map[uint64]time.Time
+}
+
+// NewTimestamps creates a timestamp cache over the client
+func NewTimestamps(client HeaderReader) *Timestamps {
+	return &Timestamps{client: client, cache: map[uint64]time.Time{}}
+}
+
+// Of the timestamp of the block
+func (timestamps *Timestamps) Of(ctx context.Context, block uint64) (time.Time, error) {
+	timestamps.mutex.Lock()
+	defer timestamps.mutex.Unlock()
+	if timestamp, ok := timestamps.cache[block]; ok {
+		return timestamp, nil
+	}
+	header, err := timestamps.client.HeaderByNumber(ctx, new(big.Int).SetUint64(block))
+	if err != nil {
+		return time.Time{}, err
+	}
+	timestamp := time.Unix(int64(header.Time), 0).UTC()
+	timestamps.cache[block] = timestamp
+	return timestamp, nil
+}
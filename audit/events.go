@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/TheLazarusNetwork/Monitor/logger"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultChunk number of blocks queried per eth_getLogs call, small enough for public providers
+const DefaultChunk = 5000
+
+// Walk calls fn with every Log event of the senders between the from and to blocks, both included,
+// querying chunk blocks at a time in chain order
+func Walk(ctx context.Context, filterer *logger.LoggerFilterer, senders []common.Address, from, to, chunk uint64, fn func(*logger.LoggerLog) error) error {
+	if chunk == 0 {
+		chunk = DefaultChunk
+	}
+	for start := from; start <= to; start += chunk {
+		end := start + chunk - 1
+		if end > to || end < start {
+			end = to
+		}
+		iterator, err := filterer.FilterLog(&bind.FilterOpts{Start: start, End: &end, Context: ctx}, senders)
+		if err != nil {
+			return err
+		}
+		for iterator.Next() {
+			if err := fn(iterator.Event); err != nil {
+				iterator.Close()
+				return err
+			}
+		}
+		err = iterator.Error()
+		iterator.Close()
+		if err != nil {
+			return err
+		}
+		if end == to {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package audit
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/TheLazarusNetwork/Monitor/merkle"
+	"github.com/TheLazarusNetwork/Monitor/model"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Status outcome of reconciling one line
+type Status string
+
+const (
+	// StatusMatched the local line is anchored with the same content
+	StatusMatched Status = "matched"
+	// StatusModified the local line differs from the anchored line at its position
+	StatusModified Status = "modified"
+	// StatusMissingOnChain the local line is not anchored although later lines are
+	StatusMissingOnChain Status = "missing on-chain"
+	// StatusMissingLocally the anchored line is not in the local file
+	StatusMissingLocally Status = "missing locally"
+	// StatusPending the local line comes after the last anchored line and may not have been anchored yet
+	StatusPending Status = "pending"
+)
+
+// LocalLine one line of the local log file, Offset is the byte offset right after it as recorded by the tailer
+type LocalLine struct {
+	Number int
+	Offset int64
+	Text   string
+}
+
+// ReadLines reads the lines of a log file along with their offsets, parse turns them into the anchored text
+func ReadLines(reader io.Reader, parse func(string) (string, error)) ([]LocalLine, error) {
+	var lines []LocalLine
+	buffered := bufio.NewReader(reader)
+	offset := int64(0)
+	for number := 1; ; number++ {
+		raw, err := buffered.ReadString('\n')
+		if err == io.EOF && raw == "" {
+			return lines, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		offset += int64(len(raw))
+		if !strings.HasSuffix(raw, "\n") {
+			// The tailer counts a newline after every line, also the unterminated last one
+			offset++
+		}
+		// Trim like the tailer, which keeps a carriage return as part of the line
+		text := strings.TrimSuffix(raw, "\n")
+		if parsed, err := parse(text); err == nil {
+			text = parsed
+		}
+		lines = append(lines, LocalLine{Number: number, Offset: offset, Text: text})
+		if err == io.EOF {
+			return lines, nil
+		}
+	}
+}
+
+// Result the outcome for one local or anchored line
+type Result struct {
+	Status   Status      `json:"status"`
+	Number   int         `json:"line,omitempty"`     // Line number in the local file, zero when missing locally
+	Offset   int64       `json:"offset,omitempty"`   // Offset after the line, zero for anchored lines known only by their hash
+	Local    string      `json:"local,omitempty"`    // Local text
+	Anchored string      `json:"anchored,omitempty"` // Anchored text, empty for lines known only by their hash
+	Leaf     common.Hash `json:"leaf"`
+	Block    uint64      `json:"block,omitempty"`
+	TxHash   common.Hash `json:"tx"`
+}
+
+// Report the outcome of a reconciliation in file order
+type Report struct {
+	Results []Result       `json:"results"`
+	Counts  map[Status]int `json:"counts"`
+}
+
+// Clean whether every line matched, lines pending anchoring only fail when strict
+func (report *Report) Clean(strict bool) bool {
+	failed := len(report.Results) - report.Counts[StatusMatched]
+	if !strict {
+		failed -= report.Counts[StatusPending]
+	}
+	return failed == 0
+}
+
+// anchoredLine a line of the file taken from a decrypted batch
+type anchoredLine struct {
+	line   model.Line
+	block  uint64
+	txHash common.Hash
+}
+
+// provenLine a line known only by its leaf hash, from a batch that could not be decrypted
+type provenLine struct {
+	leaf   common.Hash
+	block  uint64
+	txHash common.Hash
+	used   bool
+}
+
+// Reconciler compares a local log file with the lines anchored for it
+type Reconciler struct {
+	File   string // Path of the file as recorded by the agent
+	Inode  uint64 // Generation of the file to compare, zero to pick it from the local file
+	lines  []anchoredLine
+	proven map[common.Hash][]*provenLine
+}
+
+// NewReconciler creates a reconciler for the file recorded under path
+func NewReconciler(path string, inode uint64) *Reconciler {
+	return &Reconciler{File: path, Inode: inode, proven: map[common.Hash][]*provenLine{}}
+}
+
+// Add takes the lines of the file from a decrypted entry
+func (reconciler *Reconciler) Add(entry *Entry) {
+	for _, line := range entry.Lines {
+		if line.File == reconciler.File && line.Kind == "" {
+			reconciler.lines = append(reconciler.lines, anchoredLine{line: line, block: entry.Block, txHash: entry.TxHash})
+		}
+	}
+}
+
+// AddProven takes the leaf hashes of a batch that could not be decrypted, their inclusion in the anchored root already verified
+func (reconciler *Reconciler) AddProven(leaves []common.Hash, block uint64, txHash common.Hash) {
+	for _, leaf := range leaves {
+		reconciler.proven[leaf] = append(reconciler.proven[leaf], &provenLine{leaf: leaf, block: block, txHash: txHash})
+	}
+}
+
+// lookahead how many lines the alignment searches ahead to resynchronise after lines were inserted or removed
+const lookahead = 64
+
+// Reconcile aligns the local lines of the file generation with inode to the anchored lines in file order,
+// lines only on one side within the lookahead are missing, lines in place of each other are modified
+func (reconciler *Reconciler) Reconcile(local []LocalLine, inode uint64) *Report {
+	if reconciler.Inode != 0 {
+		inode = reconciler.Inode
+	}
+	// Copies of the file have another inode, compare every anchored line when none has the local one
+	filter := false
+	for _, anchored := range reconciler.lines {
+		if anchored.line.Inode == inode {
+			filter = true
+			break
+		}
+	}
+	// A line anchored twice is compared once, lines of other generations at the same offset are distinct lines
+	type key struct {
+		inode  uint64
+		offset int64
+	}
+	var lines []anchoredLine
+	seen := map[key]bool{}
+	generations := map[uint64]int{}
+	for _, anchored := range reconciler.lines {
+		at := key{anchored.line.Inode, anchored.line.Offset}
+		if filter && anchored.line.Inode != inode || seen[at] {
+			continue
+		}
+		seen[at] = true
+		if _, ok := generations[at.inode]; !ok {
+			generations[at.inode] = len(generations)
+		}
+		lines = append(lines, anchored)
+	}
+	// Generations follow each other in the order they were anchored, lines within one in file order
+	sort.SliceStable(lines, func(i, j int) bool {
+		a, b := lines[i].line, lines[j].line
+		if a.Inode != b.Inode {
+			return generations[a.Inode] < generations[b.Inode]
+		}
+		return a.Offset < b.Offset
+	})
+
+	report := &Report{Counts: map[Status]int{}}
+	add := func(result Result) {
+		report.Results = append(report.Results, result)
+		report.Counts[result.Status]++
+	}
+	anchoredResult := func(status Status, anchored anchoredLine) Result {
+		return Result{Status: status, Offset: anchored.line.Offset, Anchored: anchored.line.Text,
+			Leaf: merkle.LeafHash([]byte(anchored.line.Text)), Block: anchored.block, TxHash: anchored.txHash}
+	}
+	unanchored := func(line LocalLine, status Status) {
+		result := Result{Status: status, Number: line.Number, Offset: line.Offset, Local: line.Text, Leaf: merkle.LeafHash([]byte(line.Text))}
+		// Lines of batches that could not be decrypted have no position, they match by hash anywhere
+		if proven := reconciler.take(result.Leaf); proven != nil {
+			result.Status, result.Block, result.TxHash = StatusMatched, proven.block, proven.txHash
+		}
+		add(result)
+	}
+
+	i, j := 0, 0
+	for i < len(local) && j < len(lines) {
+		if local[i].Text == lines[j].line.Text {
+			result := anchoredResult(StatusMatched, lines[j])
+			result.Number, result.Local = local[i].Number, local[i].Text
+			add(result)
+			i, j = i+1, j+1
+			continue
+		}
+		if skip := find(len(lines)-j, func(k int) bool { return lines[j+k].line.Text == local[i].Text }); skip > 0 {
+			for ; skip > 0; skip, j = skip-1, j+1 {
+				add(anchoredResult(StatusMissingLocally, lines[j]))
+			}
+			continue
+		}
+		if skip := find(len(local)-i, func(k int) bool { return local[i+k].Text == lines[j].line.Text }); skip > 0 {
+			for ; skip > 0; skip, i = skip-1, i+1 {
+				unanchored(local[i], StatusMissingOnChain)
+			}
+			continue
+		}
+		result := anchoredResult(StatusModified, lines[j])
+		result.Number, result.Local, result.Offset = local[i].Number, local[i].Text, local[i].Offset
+		add(result)
+		i, j = i+1, j+1
+	}
+	for ; j < len(lines); j++ {
+		add(anchoredResult(StatusMissingLocally, lines[j]))
+	}
+	for ; i < len(local); i++ {
+		unanchored(local[i], StatusPending)
+	}
+	for _, proven := range reconciler.proven {
+		for _, line := range proven {
+			if !line.used {
+				add(Result{Status: StatusMissingLocally, Leaf: line.leaf, Block: line.block, TxHash: line.txHash})
+			}
+		}
+	}
+	return report
+}
+
+// find the smallest k within the lookahead and below n for which match holds, 0 when there is none
+func find(n int, match func(k int) bool) int {
+	if n > lookahead {
+		n = lookahead
+	}
+	for k := 1; k < n; k++ {
+		if match(k) {
+			return k
+		}
+	}
+	return 0
+}
+
+// take claims an unused proven line with the leaf hash
+func (reconciler *Reconciler) take(leaf common.Hash) *provenLine {
+	for _, line := range reconciler.proven[leaf] {
+		if !line.used {
+			line.used = true
+			return line
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Kind what is wrong with the chain of a source
+type Kind string
+
+const (
+	// KindMissing a sequence number was never anchored
+	KindMissing Kind = "missing"
+	// KindReordered a batch was anchored after a later batch of its source
+	KindReordered Kind = "reordered"
+	// KindDuplicate a sequence number was anchored more than once
+	KindDuplicate Kind = "duplicate"
+	// KindBreak a batch does not link to the anchored batch before it
+	KindBreak Kind = "break"
+	// KindSignature the batch statement is not signed by the sender of the transaction
+	KindSignature Kind = "signature"
+	// KindMalformed the event does not hold a decodable anchor
+	KindMalformed Kind = "malformed"
+	// KindUnchained the anchor predates sequence numbers and cannot be placed in a chain
+	KindUnchained Kind = "unchained"
+)
+
+// Issue one problem found in the chain of a source
+type Issue struct {
+	Kind     Kind
+	Sender   common.Address
+	Source   string
+	Sequence uint64
+	Block    uint64      // Block of the offending event, zero for missing sequence numbers
+	TxHash   common.Hash // Transaction of the offending event
+	Detail   string
+}
+
+// String one line description of the issue
+func (issue Issue) String() string {
+	location := ""
+	if issue.Block != 0 {
+		location = fmt.Sprintf(" (block %d, tx %s)", issue.Block, issue.TxHash.Hex())
+	}
+	return fmt.Sprintf("%s %s/%s #%d%s: %s", issue.Kind, issue.Sender.Hex(), issue.Source, issue.Sequence, location, issue.Detail)
+}
+
+// link one anchored batch of a chain
+type link struct {
+	previous common.Hash
+	hash     common.Hash
+	block    uint64
+	txHash   common.Hash
+}
+
+// chain the anchored batches of one source of one sender
+type chain struct {
+	sender common.Address
+	source string
+	links  map[uint64]link
+	last   uint64
+}
+
+// Verifier follows the Log events of one or more agents in chain order and checks that the batches of every source
+// form an unbroken chain of sequence numbers and previous hashes, signed by the agent that sent them
+type Verifier struct {
+	Genesis bool // The events start at the deployment of the contract, so every chain must start at 1
+	chains  map[string]*chain
+	issues  []Issue
+}
+
+// NewVerifier creates a verifier, genesis when the events are read from the deployment of the contract on
+func NewVerifier(genesis bool) *Verifier {
+	return &Verifier{Genesis: genesis, chains: map[string]*chain{}}
+}
+
+// Add checks the next Log event, events must be added in the order they were logged
+func (verifier *Verifier) Add(event *logger.LoggerLog) {
+	issue := Issue{Sender: event.Sender, Block: event.Raw.BlockNumber, TxHash: event.Raw.TxHash}
+	if _, ok := LegacyCiphertext(event.Data); ok {
+		issue.Kind, issue.Detail = KindUnchained, "baseline entry of a single line"
+		verifier.issues = append(verifier.issues, issue)
+		return
+	}
+	anchor, err := batch.DecodeAnchor(event.Data)
+	if err != nil {
+		issue.Kind, issue.Detail = KindMalformed, err.Error()
+		verifier.issues = append(verifier.issues, issue)
+		return
+	}
+	statement := anchor.Statement()
+	if statement == nil {
+		issue.Kind, issue.Detail = KindUnchained, fmt.Sprintf("version %d anchor of root %s", anchor.Version, anchor.Root.Hex())
+		verifier.issues = append(verifier.issues, issue)
+		return
+	}
+	issue.Source, issue.Sequence = statement.Source, statement.Sequence
+	if err := statement.Verify(anchor.Signature, event.Sender); err != nil {
+		signed := issue
+		signed.Kind, signed.Detail = KindSignature, err.Error()
+		verifier.issues = append(verifier.issues, signed)
+	}
+
+	key := event.Sender.Hex() + "/" + statement.Source
+	c, ok := verifier.chains[key]
+	if !ok {
+		c = &chain{sender: event.Sender, source: statement.Source, links: map[uint64]link{}}
+		verifier.chains[key] = c
+	}
+	current := link{previous: statement.Previous, hash: statement.Hash(), block: issue.Block, txHash: issue.TxHash}
+	if seen, ok := c.links[statement.Sequence]; ok {
+		issue.Kind = KindDuplicate
+		issue.Detail = fmt.Sprintf("already anchored in block %d", seen.block)
+		if seen.hash != current.hash {
+			issue.Detail += " with a different statement"
+		}
+		verifier.issues = append(verifier.issues, issue)
+		return
+	}
+	if statement.Sequence < c.last {
+		issue.Kind, issue.Detail = KindReordered, fmt.Sprintf("anchored after #%d", c.last)
+		verifier.issues = append(verifier.issues, issue)
+	} else {
+		c.last = statement.Sequence
+	}
+	c.links[statement.Sequence] = current
+}
+
+// Issues checks the links of every chain and returns all issues found, sorted by sender, source and sequence
+func (verifier *Verifier) Issues() []Issue {
+	issues := append([]Issue(nil), verifier.issues...)
+	for _, c := range verifier.chains {
+		sequences := make([]uint64, 0, len(c.links))
+		for sequence := range c.links {
+			sequences = append(sequences, sequence)
+		}
+		sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+
+		first := sequences[0]
+		if verifier.Genesis {
+			first = 1
+		}
+		for sequence := first; sequence <= c.last; sequence++ {
+			current, ok := c.links[sequence]
+			if !ok {
+				issues = append(issues, Issue{Kind: KindMissing, Sender: c.sender, Source: c.source, Sequence: sequence, Detail: "never anchored"})
+				continue
+			}
+			issue := Issue{Kind: KindBreak, Sender: c.sender, Source: c.source, Sequence: sequence, Block: current.block, TxHash: current.txHash}
+			if sequence == 1 && current.previous != (common.Hash{}) {
+				issue.Detail = "first batch links to " + current.previous.Hex()
+				issues = append(issues, issue)
+			} else if before, ok := c.links[sequence-1]; ok && before.hash != current.previous {
+				issue.Detail = fmt.Sprintf("links to %s instead of #%d %s", current.previous.Hex(), sequence-1, before.hash.Hex())
+				issues = append(issues, issue)
+			}
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Sender != issues[j].Sender {
+			return issues[i].Sender.Hex() < issues[j].Sender.Hex()
+		}
+		if issues[i].Source != issues[j].Source {
+			return issues[i].Source < issues[j].Source
+		}
+		return issues[i].Sequence < issues[j].Sequence
+	})
+	return issues
+}
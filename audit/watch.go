@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/logger"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// Backend a node able to filter and subscribe to logs, such as ethclient.Client over websocket or IPC
+type Backend interface {
+	bind.ContractFilterer
+	HeaderReader
+}
+
+// maxRetryDelay upper bound of the delay between resubscription attempts
+const maxRetryDelay = time.Minute
+
+// Follower streams the Log events of the senders as they are logged, resubscribing after a disconnect and
+// backfilling the blocks missed meanwhile so every event is delivered once and in order
+type Follower struct {
+	backend  Backend
+	filterer *logger.LoggerFilterer
+	senders  []common.Address
+	chunk    uint64
+	block    uint64 // Block of the last delivered event
+	index    uint   // Index of the last delivered event in its block
+	started  bool   // Whether any event was delivered yet
+	resume   uint64 // Block to catch up from when no event was delivered yet
+}
+
+// NewFollower creates a follower of the Logger contract at address
+func NewFollower(backend Backend, address common.Address, senders []common.Address, chunk uint64) (*Follower, error) {
+	filterer, err := logger.NewLoggerFilterer(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Follower{backend: backend, filterer: filterer, senders: senders, chunk: chunk}, nil
+}
+
+// Run delivers the events from block from on, or only new events when from is 0, until ctx is done
+func (follower *Follower) Run(ctx context.Context, from uint64, fn func(*logger.LoggerLog) error) error {
+	delay := time.Second
+	for {
+		err := follower.follow(ctx, from, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err, ok := err.(deliveryError); ok {
+			return err.error
+		}
+		log.Warnf("Error in following Log events, resubscribing in %s: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+		if follower.started {
+			// Catch up from the block of the last delivered event, events up to it are skipped
+			from = follower.block
+		} else if follower.resume != 0 {
+			from = follower.resume
+		}
+	}
+}
+
+// deliveryError an error returned by the callback, which stops the follower
+type deliveryError struct {
+	error
+}
+
+// retryError a temporary error of the callback, the follower resubscribes and delivers the event again
+type retryError struct {
+	error
+}
+
+// Retry marks an error of the callback as temporary, such as a failed call to the node, so the
+// follower resubscribes from the last delivered event instead of stopping
+func Retry(err error) error {
+	return retryError{err}
+}
+
+// follow subscribes, backfills the blocks from from to the head and streams events until the subscription fails
+func (follower *Follower) follow(ctx context.Context, from uint64, fn func(*logger.LoggerLog) error) error {
+	events := make(chan *logger.LoggerLog, 128)
+	// Subscribe before backfilling, events logged in between arrive through both and are delivered once
+	subscription, err := follower.filterer.WatchLog(&bind.WatchOpts{Context: ctx}, events, follower.senders)
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+
+	head, err := follower.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if from == 0 {
+		follower.resume = head.Number.Uint64() + 1
+	} else {
+		follower.resume = from
+		err = Walk(ctx, follower.filterer, follower.senders, from, head.Number.Uint64(), follower.chunk, func(event *logger.LoggerLog) error {
+			return follower.deliver(event, fn)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-subscription.Err():
+			return err
+		case event := <-events:
+			if err := follower.deliver(event, fn); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// deliver passes on events past the last delivered one, events reverted by a reorganisation are only reported
+func (follower *Follower) deliver(event *logger.LoggerLog, fn func(*logger.LoggerLog) error) error {
+	if event.Raw.Removed {
+		log.Warnf("Log event of tx %s in block %d was reverted by a reorganisation", event.Raw.TxHash.Hex(), event.Raw.BlockNumber)
+		return nil
+	}
+	if follower.started && (event.Raw.BlockNumber < follower.block || event.Raw.BlockNumber == follower.block && event.Raw.Index <= follower.index) {
+		return nil
+	}
+	if err := fn(event); err != nil {
+		if _, ok := err.(retryError); ok {
+			return err
+		}
+		return deliveryError{err}
+	}
+	follower.block, follower.index, follower.started = event.Raw.BlockNumber, event.Raw.Index, true
+	return nil
+}
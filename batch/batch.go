@@ -0,0 +1,259 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/merkle"
+	"github.com/TheLazarusNetwork/Monitor/model"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Batch a group of log lines anchored on-chain through a single Merkle root
+type Batch struct {
+	Source    string
+	Lines     []model.Line
+	Tree      *merkle.Tree
+	Sequence  uint64      // Position of the batch among the batches of its source
+	Previous  common.Hash // Statement hash of the previous batch of the source, zero for the first
+	Time      time.Time   // When the batch was sealed
+	Signature []byte      // Agent signature over the batch statement
+	Payload   []byte      // Encrypted batch contents
+	Pointer   string      // Location of the encrypted batch
+}
+
+// New builds the Merkle tree over the lines of a batch, all lines come from the same source
+func New(lines []model.Line) (*Batch, error) {
+	leaves := make([][]byte, len(lines))
+	for i, line := range lines {
+		leaves[i] = []byte(line.Text)
+	}
+	tree, err := merkle.New(leaves)
+	if err != nil {
+		return nil, err
+	}
+	return &Batch{Source: lines[0].Source, Lines: lines, Tree: tree, Time: time.Now().UTC()}, nil
+}
+
+// Root Merkle root of the batch
+func (b *Batch) Root() common.Hash {
+	return b.Tree.Root()
+}
+
+// Anchor returns the record written on-chain for the batch
+func (b *Batch) Anchor() *Anchor {
+	return &Anchor{
+		Version:   AnchorVersion,
+		Root:      b.Root(),
+		Count:     len(b.Lines),
+		Source:    b.Source,
+		Sequence:  b.Sequence,
+		Previous:  b.Previous,
+		Time:      b.Time,
+		Signature: b.Signature,
+		Pointer:   b.Pointer,
+		Payload:   b.Payload,
+	}
+}
+
+// AnchorVersion current version of the on-chain anchor record
+const AnchorVersion = 3
+
+// Anchor the record emitted through the Logger contract for every batch
+//
+// Version 1 anchors are JSON without the payload. Later versions are binary, version 2 is
+//
+//	version (1) | root (32) | count (4) | pointer length (2) | pointer | payload
+//
+// and version 3 adds the signed statement of the batch after the count
+//
+//	sequence (8) | previous (32) | time (8, unix nanoseconds) | source length (1) | source | signature length (1) | signature
+//
+// with the encrypted payload kept as raw bytes in the event's data string
+type Anchor struct {
+	Version   int         `json:"v"`
+	Root      common.Hash `json:"root"`
+	Count     int         `json:"count"`
+	Source    string      `json:"-"`
+	Sequence  uint64      `json:"-"`
+	Previous  common.Hash `json:"-"`
+	Time      time.Time   `json:"-"`
+	Signature []byte      `json:"-"`
+	Pointer   string      `json:"ptr"`
+	Payload   []byte      `json:"-"`
+}
+
+// Encode serialises the anchor into the contract's data string
+func (a *Anchor) Encode() (string, error) {
+	if a.Version == 1 {
+		data, err := json.Marshal(a)
+		return string(data), err
+	}
+	if a.Version != 2 && a.Version != AnchorVersion {
+		return "", fmt.Errorf("unsupported anchor version %d", a.Version)
+	}
+	if len(a.Pointer) > math.MaxUint16 {
+		return "", fmt.Errorf("anchor pointer of %d bytes is too long", len(a.Pointer))
+	}
+	if len(a.Source) > math.MaxUint8 || len(a.Signature) > math.MaxUint8 {
+		return "", fmt.Errorf("anchor source or signature is too long")
+	}
+	var buffer bytes.Buffer
+	buffer.WriteByte(byte(a.Version))
+	buffer.Write(a.Root.Bytes())
+	binary.Write(&buffer, binary.BigEndian, uint32(a.Count))
+	if a.Version >= 3 {
+		binary.Write(&buffer, binary.BigEndian, a.Sequence)
+		buffer.Write(a.Previous.Bytes())
+		binary.Write(&buffer, binary.BigEndian, a.Time.UnixNano())
+		buffer.WriteByte(byte(len(a.Source)))
+		buffer.WriteString(a.Source)
+		buffer.WriteByte(byte(len(a.Signature)))
+		buffer.Write(a.Signature)
+	}
+	binary.Write(&buffer, binary.BigEndian, uint16(len(a.Pointer)))
+	buffer.WriteString(a.Pointer)
+	buffer.Write(a.Payload)
+	return buffer.String(), nil
+}
+
+// DecodeAnchor parses the data string of a Log event
+func DecodeAnchor(data string) (*Anchor, error) {
+	anchor := &Anchor{}
+	if strings.HasPrefix(data, "{") {
+		err := json.Unmarshal([]byte(data), anchor)
+		return anchor, err
+	}
+	reader := strings.NewReader(data)
+	version, err := reader.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("empty anchor")
+	}
+	if version < 2 || version > AnchorVersion {
+		return nil, fmt.Errorf("unsupported anchor version %d", version)
+	}
+	anchor.Version = int(version)
+	var count uint32
+	if _, err := io.ReadFull(reader, anchor.Root[:]); err != nil {
+		return nil, errTruncated(err)
+	}
+	if err := binary.Read(reader, binary.BigEndian, &count); err != nil {
+		return nil, errTruncated(err)
+	}
+	anchor.Count = int(count)
+	if anchor.Version >= 3 {
+		var nanos int64
+		if err := binary.Read(reader, binary.BigEndian, &anchor.Sequence); err != nil {
+			return nil, errTruncated(err)
+		}
+		if _, err := io.ReadFull(reader, anchor.Previous[:]); err != nil {
+			return nil, errTruncated(err)
+		}
+		if err := binary.Read(reader, binary.BigEndian, &nanos); err != nil {
+			return nil, errTruncated(err)
+		}
+		anchor.Time = time.Unix(0, nanos).UTC()
+		source, err := readShort(reader)
+		if err != nil {
+			return nil, err
+		}
+		anchor.Source = string(source)
+		if anchor.Signature, err = readShort(reader); err != nil {
+			return nil, err
+		}
+	}
+	var size uint16
+	if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+		return nil, errTruncated(err)
+	}
+	pointer := make([]byte, size)
+	if _, err := io.ReadFull(reader, pointer); err != nil {
+		return nil, errTruncated(err)
+	}
+	anchor.Pointer = string(pointer)
+	anchor.Payload = []byte(data[len(data)-reader.Len():])
+	return anchor, nil
+}
+
+// readShort reads a field prefixed with its one byte length
+func readShort(reader *strings.Reader) ([]byte, error) {
+	size, err := reader.ReadByte()
+	if err != nil {
+		return nil, errTruncated(err)
+	}
+	field := make([]byte, size)
+	if _, err := io.ReadFull(reader, field); err != nil {
+		return nil, errTruncated(err)
+	}
+	return field, nil
+}
+
+func errTruncated(err error) error {
+	return fmt.Errorf("anchor is truncated: %v", err)
+}
+
+// Batcher groups lines of the same source into batches bounded by a line count and a time window
+type Batcher struct {
+	MaxLines int
+	Window   time.Duration
+}
+
+// pending lines of one source waiting for their batch to fill up
+type pending struct {
+	lines    []model.Line
+	deadline time.Time
+}
+
+// Run reads lines until in is closed and emits the collected lines of each source on out
+func (batcher *Batcher) Run(in <-chan model.Line, out chan<- []model.Line) {
+	defer close(out)
+	groups := map[string]*pending{}
+	flush := func(source string) {
+		out <- groups[source].lines
+		delete(groups, source)
+	}
+	for {
+		// Wake up when the oldest pending batch reaches the end of its window
+		var next time.Time
+		for _, group := range groups {
+			if next.IsZero() || group.deadline.Before(next) {
+				next = group.deadline
+			}
+		}
+		var wait <-chan time.Time
+		if !next.IsZero() {
+			wait = time.After(time.Until(next))
+		}
+		select {
+		case line, ok := <-in:
+			if !ok {
+				for source := range groups {
+					flush(source)
+				}
+				return
+			}
+			group, ok := groups[line.Source]
+			if !ok {
+				group = &pending{lines: make([]model.Line, 0, batcher.MaxLines), deadline: time.Now().Add(batcher.Window)}
+				groups[line.Source] = group
+			}
+			group.lines = append(group.lines, line)
+			if len(group.lines) >= batcher.MaxLines {
+				flush(line.Source)
+			}
+		case <-wait:
+			for source, group := range groups {
+				if !time.Now().Before(group.deadline) {
+					flush(source)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package batch
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/merkle"
+	"github.com/TheLazarusNetwork/Monitor/model"
+	"github.com/TheLazarusNetwork/Monitor/transactor"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestBatcher Lines are grouped per source and flushed on size, window and close
+func TestBatcher(t *testing.T) {
+	in := make(chan model.Line)
+	out := make(chan []model.Line, 10)
+	batcher := &Batcher{MaxLines: 2, Window: 50 * time.Millisecond}
+	go batcher.Run(in, out)
+
+	in <- model.Line{Source: "nginx", Text: "a"}
+	in <- model.Line{Source: "app", Text: "b"}
+	in <- model.Line{Source: "nginx", Text: "c"}
+
+	full := <-out
+	if len(full) != 2 || full[0].Text != "a" || full[1].Text != "c" {
+		t.Fatalf("Expected the full nginx batch first, received %+v", full)
+	}
+	windowed := <-out
+	if len(windowed) != 1 || windowed[0].Source != "app" {
+		t.Fatalf("Expected the app batch after its window, received %+v", windowed)
+	}
+
+	in <- model.Line{Source: "app", Text: "d"}
+	close(in)
+	if rest := <-out; len(rest) != 1 || rest[0].Text != "d" {
+		t.Fatalf("Expected pending lines to be flushed on close, received %+v", rest)
+	}
+	if _, ok := <-out; ok {
+		t.Error("Expected the output channel to be closed")
+	}
+}
+
+// TestBatchProofs Every line of a batch verifies against the anchored root
+func TestBatchProofs(t *testing.T) {
+	b, err := New([]model.Line{{Source: "nginx", Text: "a"}, {Source: "nginx", Text: "b"}, {Source: "nginx", Text: "c"}})
+	if err != nil {
+		t.Fatalf("Failed to build batch: %v", err)
+	}
+	for i, line := range b.Lines {
+		if !merkle.Verify(b.Anchor().Root, []byte(line.Text), b.Tree.Proof(i)) {
+			t.Errorf("Line %d did not verify against the anchor root", i)
+		}
+	}
+}
+
+// TestAnchorEncoding Binary anchors round trip with their raw payload and JSON anchors still decode
+func TestAnchorEncoding(t *testing.T) {
+	b, err := New([]model.Line{{Source: "nginx", Text: "a"}, {Source: "nginx", Text: "b"}, {Source: "nginx", Text: "c"}})
+	if err != nil {
+		t.Fatalf("Failed to build batch: %v", err)
+	}
+	b.Pointer = "file:///var/lib/monitor/batch.bin"
+	b.Payload = []byte{0x01, 0x00, 0xff, '{'}
+
+	data, err := b.Anchor().Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode anchor: %v", err)
+	}
+	anchor, err := DecodeAnchor(data)
+	if err != nil {
+		t.Fatalf("Failed to decode anchor: %v", err)
+	}
+	if anchor.Version != AnchorVersion || anchor.Root != b.Root() || anchor.Count != 3 || anchor.Pointer != b.Pointer || !bytes.Equal(anchor.Payload, b.Payload) {
+		t.Errorf("Expected the anchor to round trip, decoded %+v", anchor)
+	}
+
+	legacy, err := DecodeAnchor(`{"v":1,"root":"` + b.Root().Hex() + `","count":3,"ptr":"file:///batch.bin"}`)
+	if err != nil || legacy.Version != 1 || legacy.Root != b.Root() || legacy.Pointer != "file:///batch.bin" {
+		t.Errorf("Expected the JSON anchor to decode, decoded %+v: %v", legacy, err)
+	}
+	if _, err := DecodeAnchor(data[:20]); err == nil {
+		t.Error("Expected a truncated anchor to be rejected")
+	}
+}
+
+// TestSignature The anchored statement verifies offline against the agent address only
+func TestSignature(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	agent := crypto.PubkeyToAddress(key.PublicKey)
+	b, err := New([]model.Line{{Source: "nginx", Text: "a"}, {Source: "nginx", Text: "b"}})
+	if err != nil {
+		t.Fatalf("Failed to build batch: %v", err)
+	}
+	b.Sequence = 42
+	if err := b.Sign(transactor.NewKeySigner(key)); err != nil {
+		t.Fatalf("Failed to sign batch: %v", err)
+	}
+
+	data, err := b.Anchor().Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode anchor: %v", err)
+	}
+	anchor, err := DecodeAnchor(data)
+	if err != nil {
+		t.Fatalf("Failed to decode anchor: %v", err)
+	}
+	statement := anchor.Statement()
+	if statement.Source != "nginx" || statement.Sequence != 42 || !statement.Time.Equal(b.Time) {
+		t.Errorf("Expected the statement to round trip, decoded %+v", statement)
+	}
+	if err := statement.Verify(anchor.Signature, agent); err != nil {
+		t.Errorf("Expected the signature to verify: %v", err)
+	}
+
+	other, _ := crypto.GenerateKey()
+	if err := statement.Verify(anchor.Signature, crypto.PubkeyToAddress(other.PublicKey)); err != ErrBadSignature {
+		t.Errorf("Expected another agent to be rejected, received %v", err)
+	}
+	statement.Sequence++
+	if err := statement.Verify(anchor.Signature, agent); err == nil {
+		t.Error("Expected a changed statement to be rejected")
+	}
+}
+
+// TestSequencer Batches of a source are numbered and linked, also across a restart
+func TestSequencer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence.json")
+	sequencer, err := LoadSequencer(path)
+	if err != nil {
+		t.Fatalf("Failed to load sequencer: %v", err)
+	}
+	position := uint64(0)
+	chain := func(sequencer *Sequencer, source string, positions ...uint64) *Batch {
+		if len(positions) == 0 {
+			position++
+			positions = []uint64{position}
+		}
+		var lines []model.Line
+		for _, position := range positions {
+			lines = append(lines, model.Line{Source: source, Text: fmt.Sprint("line ", position), Position: position})
+		}
+		b, err := New(lines)
+		if err != nil {
+			t.Fatalf("Failed to build batch: %v", err)
+		}
+		if err := sequencer.Chain(b); err != nil {
+			t.Fatalf("Failed to chain batch: %v", err)
+		}
+		return b
+	}
+	confirm := func(sequencer *Sequencer, batches ...*Batch) {
+		for _, b := range batches {
+			if err := sequencer.Confirm(b); err != nil {
+				t.Fatalf("Failed to confirm batch: %v", err)
+			}
+		}
+	}
+	first := chain(sequencer, "nginx")
+	second := chain(sequencer, "nginx")
+	app := chain(sequencer, "app")
+	if first.Sequence != 1 || first.Previous != (common.Hash{}) || second.Sequence != 2 || second.Previous != first.Statement().Hash() {
+		t.Errorf("Expected the second nginx batch to link to the first, received %d->%d", first.Sequence, second.Sequence)
+	}
+	if app.Sequence != 1 {
+		t.Errorf("Expected app to be numbered on its own, received %d", app.Sequence)
+	}
+	confirm(sequencer, first, second, app)
+
+	reloaded, err := LoadSequencer(path)
+	if err != nil {
+		t.Fatalf("Failed to reload sequencer: %v", err)
+	}
+	if third := chain(reloaded, "nginx"); third.Sequence != 3 || third.Previous != second.Statement().Hash() {
+		t.Errorf("Expected nginx to continue at 3 after the second batch, received %d", third.Sequence)
+	}
+}
+
+// TestSequencerReanchor Lines of a failed anchor are chained again with the same statement after a restart
+func TestSequencerReanchor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence.json")
+	sequencer, err := LoadSequencer(path)
+	if err != nil {
+		t.Fatalf("Failed to load sequencer: %v", err)
+	}
+	build := func(positions ...uint64) *Batch {
+		var lines []model.Line
+		for _, position := range positions {
+			lines = append(lines, model.Line{Source: "nginx", Text: fmt.Sprint("line ", position), Position: position})
+		}
+		b, err := New(lines)
+		if err != nil {
+			t.Fatalf("Failed to build batch: %v", err)
+		}
+		return b
+	}
+	failed := build(1, 2)
+	if err := sequencer.Chain(failed); err != nil {
+		t.Fatalf("Failed to chain batch: %v", err)
+	}
+	next := build(3)
+	if err := sequencer.Chain(next); err != nil {
+		t.Fatalf("Failed to chain batch: %v", err)
+	}
+	if err := sequencer.Confirm(next); err != nil {
+		t.Fatalf("Failed to confirm batch: %v", err)
+	}
+
+	reloaded, err := LoadSequencer(path)
+	if err != nil {
+		t.Fatalf("Failed to reload sequencer: %v", err)
+	}
+	if positions, ok := reloaded.Reserved(2); !ok || len(positions) != 2 {
+		t.Errorf("Expected the lines of the failed batch to stay reserved, received %v", positions)
+	}
+	if _, ok := reloaded.Reserved(3); ok {
+		t.Errorf("Expected the confirmed batch to be released")
+	}
+	retried := build(1, 2)
+	if err := reloaded.Chain(retried); err != nil {
+		t.Fatalf("Failed to chain batch: %v", err)
+	}
+	if retried.Sequence != 1 || retried.Statement().Hash() != failed.Statement().Hash() {
+		t.Errorf("Expected the retried batch to keep sequence 1 and its statement, received %d", retried.Sequence)
+	}
+	after := build(4)
+	if err := reloaded.Chain(after); err != nil {
+		t.Fatalf("Failed to chain batch: %v", err)
+	}
+	if after.Sequence != 3 || after.Previous != next.Statement().Hash() {
+		t.Errorf("Expected the chain to continue at 3 after the retried batch, received %d", after.Sequence)
+	}
+}
+
+// TestStore Batches of the same lines keep their own files and receipts
+func TestStore(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	build := func(sequence uint64) *Batch {
+		b, err := New([]model.Line{{Source: "app", Text: "heartbeat"}})
+		if err != nil {
+			t.Fatalf("Failed to build batch: %v", err)
+		}
+		b.Sequence = sequence
+		return b
+	}
+	first, second := build(1), build(2)
+	if first.Root() != second.Root() || first.Key() == second.Key() {
+		t.Fatal("Expected equal roots but distinct keys")
+	}
+	for _, b := range []*Batch{first, second} {
+		b.Payload = []byte{byte(b.Sequence)}
+		if err := store.Save(b); err != nil {
+			t.Fatalf("Failed to save batch: %v", err)
+		}
+	}
+	if payload, err := store.LoadPayload(first.Key()); err != nil || !bytes.Equal(payload, []byte{1}) {
+		t.Errorf("Expected the first payload to survive the second batch, received %v (%v)", payload, err)
+	}
+	if proofs, err := store.LoadProofs(second.Anchor().Key()); err != nil || proofs.Sequence != 2 {
+		t.Errorf("Expected the proofs of the second batch under its anchor key, received %+v (%v)", proofs, err)
+	}
+	if err := store.SaveReceipt(first.Key(), &transactor.Receipt{Status: transactor.StatusPending}); err != nil {
+		t.Fatalf("Failed to save receipt: %v", err)
+	}
+	if _, err := store.LoadReceipt(second.Key()); err == nil {
+		t.Error("Expected the pending receipt of the first batch not to apply to the second")
+	}
+}
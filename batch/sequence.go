@@ -0,0 +1,166 @@
+package batch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Link the last batch of a source, the next batch continues from it
+type Link struct {
+	Sequence uint64      `json:"sequence"`
+	Hash     common.Hash `json:"hash"`
+}
+
+// Reservation a chained batch that is not confirmed yet, its lines are anchored again with the same statement
+type Reservation struct {
+	Source    string      `json:"source"`
+	Sequence  uint64      `json:"sequence"`
+	Previous  common.Hash `json:"previous"`
+	Time      time.Time   `json:"time"`
+	Positions []uint64    `json:"positions"` // Spool positions of the lines
+}
+
+// Sequencer chains the batches of every source through sequence numbers and statement hashes,
+// persisted in a local file so the chain carries on across restarts
+type Sequencer struct {
+	path     string
+	mutex    sync.Mutex
+	links    map[string]Link
+	reserved []Reservation
+}
+
+// sequenceFile layout of the sequence file
+type sequenceFile struct {
+	Links    map[string]Link `json:"links"`
+	Reserved []Reservation   `json:"reserved"`
+}
+
+// LoadSequencer reads the sequence file, a missing file starts every source at 1
+func LoadSequencer(path string) (*Sequencer, error) {
+	sequencer := &Sequencer{path: path, links: map[string]Link{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return sequencer, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file sequenceFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Links != nil {
+		sequencer.links = file.Links
+	}
+	sequencer.reserved = file.Reserved
+	return sequencer, nil
+}
+
+// Chain numbers the batch after the last batch of its source and links it to that batch. A batch of
+// the same lines as an unconfirmed one takes over its sequence, link and time, so a failed or
+// interrupted anchor is retried with the same statement instead of leaving a gap in the chain
+func (sequencer *Sequencer) Chain(b *Batch) error {
+	sequencer.mutex.Lock()
+	defer sequencer.mutex.Unlock()
+	positions := positionsOf(b)
+	for _, reservation := range sequencer.reserved {
+		if reservation.Source == b.Source && equalPositions(reservation.Positions, positions) {
+			b.Sequence, b.Previous, b.Time = reservation.Sequence, reservation.Previous, reservation.Time
+			return nil
+		}
+	}
+
+	last := sequencer.links[b.Source]
+	b.Sequence = last.Sequence + 1
+	b.Previous = last.Hash
+	sequencer.links[b.Source] = Link{Sequence: b.Sequence, Hash: b.Statement().Hash()}
+	sequencer.reserved = append(sequencer.reserved, Reservation{Source: b.Source, Sequence: b.Sequence, Previous: b.Previous, Time: b.Time, Positions: positions})
+	if err := sequencer.save(); err != nil {
+		sequencer.links[b.Source] = last
+		sequencer.reserved = sequencer.reserved[:len(sequencer.reserved)-1]
+		return err
+	}
+	return nil
+}
+
+// Confirm releases the reservation of an anchored batch
+func (sequencer *Sequencer) Confirm(b *Batch) error {
+	sequencer.mutex.Lock()
+	defer sequencer.mutex.Unlock()
+	for i, reservation := range sequencer.reserved {
+		if reservation.Source == b.Source && reservation.Sequence == b.Sequence {
+			sequencer.reserved = append(sequencer.reserved[:i:i], sequencer.reserved[i+1:]...)
+			return sequencer.save()
+		}
+	}
+	return nil
+}
+
+// Reserved the spool positions of the unconfirmed batch holding the line at position
+func (sequencer *Sequencer) Reserved(position uint64) ([]uint64, bool) {
+	sequencer.mutex.Lock()
+	defer sequencer.mutex.Unlock()
+	for _, reservation := range sequencer.reserved {
+		for _, reserved := range reservation.Positions {
+			if reserved == position {
+				return reservation.Positions, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// save writes the links and reservations atomically through a temporary file
+func (sequencer *Sequencer) save() error {
+	data, err := json.MarshalIndent(sequenceFile{Links: sequencer.links, Reserved: sequencer.reserved}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(sequencer.path), filepath.Base(sequencer.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), sequencer.path)
+}
+
+// positionsOf the spool positions of the lines of a batch
+func positionsOf(b *Batch) []uint64 {
+	positions := make([]uint64, len(b.Lines))
+	for i, line := range b.Lines {
+		positions[i] = line.Position
+	}
+	return positions
+}
+
+// equalPositions reports whether two batches hold the same lines
+func equalPositions(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
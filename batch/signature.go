@@ -0,0 +1,123 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// statementDomain separates batch statements from anything else the agent key signs
+const statementDomain = "lazarus-monitor/batch/v1"
+
+// ErrBadSignature the signature is not the expected agent's signature over the statement
+var ErrBadSignature = errors.New("batch signature does not match the agent")
+
+// Statement what the agent signs for every batch, the root commits to the content of all its lines
+// and the previous hash to every earlier batch of the source
+type Statement struct {
+	Source   string
+	Sequence uint64
+	Previous common.Hash
+	Time     time.Time
+	Count    int
+	Root     common.Hash
+}
+
+// TextSigner signs EIP-191 text messages with the agent's key, held locally or by an external signer
+type TextSigner interface {
+	SignText(text []byte) ([]byte, error)
+}
+
+// Statement the statement of the batch
+func (b *Batch) Statement() *Statement {
+	return &Statement{Source: b.Source, Sequence: b.Sequence, Previous: b.Previous, Time: b.Time, Count: len(b.Lines), Root: b.Root()}
+}
+
+// Key identifies the batch in the local store by its statement hash, which tells apart batches of the same lines
+func (b *Batch) Key() common.Hash {
+	return b.Statement().Hash()
+}
+
+// Sign signs the statement of the batch
+func (b *Batch) Sign(signer TextSigner) error {
+	signature, err := signer.SignText(b.Statement().Encode())
+	if err != nil {
+		return err
+	}
+	b.Signature = signature
+	return nil
+}
+
+// Statement the signed statement carried by the anchor, nil before version 3
+func (a *Anchor) Statement() *Statement {
+	if a.Version < 3 {
+		return nil
+	}
+	return &Statement{Source: a.Source, Sequence: a.Sequence, Previous: a.Previous, Time: a.Time, Count: a.Count, Root: a.Root}
+}
+
+// Key identifies the anchored batch in the local store, its statement hash or the root of anchors without a statement
+func (a *Anchor) Key() common.Hash {
+	if statement := a.Statement(); statement != nil {
+		return statement.Hash()
+	}
+	return a.Root
+}
+
+// Encode the canonical encoding of the statement
+//
+//	domain | source length (2) | source | sequence (8) | previous (32) | time (8, unix nanoseconds) | count (4) | root (32)
+//
+// with integers in big endian
+func (statement *Statement) Encode() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(statementDomain)
+	binary.Write(&buffer, binary.BigEndian, uint16(len(statement.Source)))
+	buffer.WriteString(statement.Source)
+	binary.Write(&buffer, binary.BigEndian, statement.Sequence)
+	buffer.Write(statement.Previous.Bytes())
+	binary.Write(&buffer, binary.BigEndian, statement.Time.UnixNano())
+	binary.Write(&buffer, binary.BigEndian, uint32(statement.Count))
+	buffer.Write(statement.Root.Bytes())
+	return buffer.Bytes()
+}
+
+// Hash the hash the next batch of the source links to
+func (statement *Statement) Hash() common.Hash {
+	return crypto.Keccak256Hash(statement.Encode())
+}
+
+// Signer recovers the address that produced a signature over the statement
+func (statement *Statement) Signer(signature []byte) (common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("signature of %d bytes, expected %d", len(signature), crypto.SignatureLength)
+	}
+	// Accept both the 0/1 and the 27/28 recovery ids signers produce
+	signature = append([]byte(nil), signature...)
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	publicKey, err := crypto.SigToPub(accounts.TextHash(statement.Encode()), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// Verify checks offline that the statement was signed by the agent at address
+func (statement *Statement) Verify(signature []byte, address common.Address) error {
+	signer, err := statement.Signer(signature)
+	if err != nil {
+		return err
+	}
+	if signer != address {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/merkle"
+	"github.com/TheLazarusNetwork/Monitor/transactor"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// LineProof inclusion proof of a single line kept locally for later verification
+type LineProof struct {
+	Index  int          `json:"index"`
+	Source string       `json:"source"`
+	File   string       `json:"file"`
+	Leaf   common.Hash  `json:"leaf"`
+	Proof  merkle.Proof `json:"proof"`
+}
+
+// Proofs all inclusion proofs of a batch along with its signed statement
+type Proofs struct {
+	Root      common.Hash   `json:"root"`
+	Source    string        `json:"source"`
+	Sequence  uint64        `json:"sequence"`
+	Previous  common.Hash   `json:"previous"`
+	Time      time.Time     `json:"time"`
+	Signature hexutil.Bytes `json:"signature"`
+	Lines     []LineProof   `json:"lines"`
+}
+
+// Statement the signed statement of the batch
+func (proofs *Proofs) Statement() *Statement {
+	return &Statement{Source: proofs.Source, Sequence: proofs.Sequence, Previous: proofs.Previous, Time: proofs.Time, Count: len(proofs.Lines), Root: proofs.Root}
+}
+
+// Store keeps encrypted batches and their inclusion proofs on local disk
+type Store struct {
+	Dir string
+}
+
+// NewStore creates the batch directory if needed
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir}, nil
+}
+
+// Save writes the encrypted payload and the inclusion proofs of the batch under its key and sets its pointer
+func (store *Store) Save(b *Batch) error {
+	key := b.Key().Hex()
+	payloadPath := filepath.Join(store.Dir, key+".bin")
+	if err := ioutil.WriteFile(payloadPath, b.Payload, 0600); err != nil {
+		return err
+	}
+
+	proofs := Proofs{
+		Root:      b.Root(),
+		Source:    b.Source,
+		Sequence:  b.Sequence,
+		Previous:  b.Previous,
+		Time:      b.Time,
+		Signature: b.Signature,
+		Lines:     make([]LineProof, len(b.Lines)),
+	}
+	for i, line := range b.Lines {
+		proofs.Lines[i] = LineProof{Index: i, Source: line.Source, File: line.File, Leaf: b.Tree.Leaf(i), Proof: b.Tree.Proof(i)}
+	}
+	data, err := json.MarshalIndent(proofs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(store.Dir, key+".proofs.json"), data, 0600); err != nil {
+		return err
+	}
+	b.Pointer = "file://" + filepath.ToSlash(payloadPath)
+	return nil
+}
+
+// LoadProofs reads back the inclusion proofs of the batch with the given key
+func (store *Store) LoadProofs(key common.Hash) (*Proofs, error) {
+	data, err := ioutil.ReadFile(filepath.Join(store.Dir, key.Hex()+".proofs.json"))
+	if err != nil {
+		return nil, err
+	}
+	proofs := &Proofs{}
+	err = json.Unmarshal(data, proofs)
+	return proofs, err
+}
+
+// SaveReceipt records the on-chain outcome of the anchor of the batch with the given key
+func (store *Store) SaveReceipt(key common.Hash, receipt *transactor.Receipt) error {
+	data, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(store.Dir, key.Hex()+".receipt.json"), data, 0600)
+}
+
+// LoadReceipt reads back the on-chain outcome of the anchor of the batch with the given key
+func (store *Store) LoadReceipt(key common.Hash) (*transactor.Receipt, error) {
+	data, err := ioutil.ReadFile(filepath.Join(store.Dir, key.Hex()+".receipt.json"))
+	if err != nil {
+		return nil, err
+	}
+	receipt := &transactor.Receipt{}
+	err = json.Unmarshal(data, receipt)
+	return receipt, err
+}
+
+// Pending the receipts of anchors still pending, with the signed versions of their transactions
+func (store *Store) Pending() ([]*transactor.Receipt, error) {
+	paths, err := filepath.Glob(filepath.Join(store.Dir, "*.receipt.json"))
+	if err != nil {
+		return nil, err
+	}
+	var pending []*transactor.Receipt
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		receipt := &transactor.Receipt{}
+		if err := json.Unmarshal(data, receipt); err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		if receipt.Status == transactor.StatusPending {
+			pending = append(pending, receipt)
+		}
+	}
+	return pending, nil
+}
+
+// LoadPayload reads back the encrypted payload of the batch with the given key
+func (store *Store) LoadPayload(key common.Hash) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(store.Dir, key.Hex()+".bin"))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/transactor"
+	"github.com/TheLazarusNetwork/Monitor/utility"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// cancel replaces the transaction pending at a nonce with a zero-value transfer to the agent itself
+//
+//	monitor cancel -nonce 42 [-bump 50] [-batches dir]
+//	monitor cancel -tx 0x... [-bump 50]
+func cancel(args []string) {
+	flags := flag.NewFlagSet("cancel", flag.ExitOnError)
+	nonce := flags.Int64("nonce", -1, "nonce of the pending anchor to cancel, looked up in the batch directory")
+	txHash := flags.String("tx", "", "hash of the pending transaction to cancel, instead of -nonce")
+	batches := flags.String("batches", viper.GetString("BATCH_DIR"), "batch directory holding the pending anchors")
+	bump := flags.Int64("bump", 50, "percent added to the current fees to outbid the pending transaction")
+	flags.Parse(args)
+	if *nonce < 0 && *txHash == "" {
+		log.Fatal("cancel needs the -nonce or the -tx of the pending transaction")
+	}
+
+	signer, _, err := newSigner()
+	utility.CheckError("Error in loading signing key:", err)
+	client, err := transactor.Dial(viper.Get("INFURA_ENDPOINT").(string))
+	utility.CheckError("Error in connecting to Infura EndPoint:", err)
+	defer client.Close()
+	ctx := context.Background()
+	chainID, err := client.ChainID(ctx)
+	utility.CheckError("Error in fetching chain id:", err)
+	auth := transactor.Transactor(signer, chainID)
+	fees, err := newFeeStrategy(client)
+	utility.CheckError("Error in configuring fee strategy:", err)
+
+	// The replacement has to outbid the fees of the pending transaction, not only the current ones
+	var previous *types.Transaction
+	if *txHash != "" {
+		var pending bool
+		previous, pending, err = client.TransactionByHash(ctx, common.HexToHash(*txHash))
+		utility.CheckError("Error in fetching transaction:", err)
+		if !pending {
+			log.Fatalf("Transaction %s is already mined", *txHash)
+		}
+	} else {
+		previous, err = pendingAt(&batch.Store{Dir: *batches}, uint64(*nonce))
+		utility.CheckError("Error in looking up pending transaction:", err)
+		if previous == nil {
+			log.Fatalf("No pending anchor at nonce %d in %s, cancel it by its -tx hash", *nonce, *batches)
+		}
+	}
+
+	replacer := transactor.NewReplacer(client, fees, signer.Address(), auth.Signer)
+	tx, err := replacer.Cancel(ctx, previous, *bump)
+	utility.CheckError("Unable to cancel transaction:", err)
+	log.Infof("TX Hash: %s --> Cancelling %s at nonce %d of %s", tx.Hash().Hex(), previous.Hash().Hex(), previous.Nonce(), auth.From.Hex())
+}
+
+// pendingAt the latest version of the pending anchor sent at nonce, nil when the store holds none
+func pendingAt(store *batch.Store, nonce uint64) (*types.Transaction, error) {
+	receipts, err := store.Pending()
+	if err != nil {
+		return nil, err
+	}
+	for _, receipt := range receipts {
+		if len(receipt.Transactions) == 0 {
+			continue
+		}
+		latest := new(types.Transaction)
+		if err := latest.UnmarshalBinary(receipt.Transactions[len(receipt.Transactions)-1]); err != nil {
+			return nil, err
+		}
+		if latest.Nonce() == nonce {
+			return latest, nil
+		}
+	}
+	return nil, nil
+}
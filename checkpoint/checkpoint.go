@@ -0,0 +1,87 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Position of the last line of a file that is safely stored
+type Position struct {
+	Offset int64  `json:"offset"` // Byte offset right after the line
+	Inode  uint64 `json:"inode"`  // Inode of the file the offset belongs to
+}
+
+// State the confirmed positions of all tailed files, persisted in a local state file
+type State struct {
+	path  string
+	mutex sync.Mutex
+	files map[string]Position
+}
+
+// Load reads the state file, a missing file yields an empty state
+func Load(path string) (*State, error) {
+	state := &State{path: path, files: map[string]Position{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state.files); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Get the confirmed position of a file
+func (state *State) Get(file string) (Position, bool) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	position, ok := state.files[file]
+	return position, ok
+}
+
+// Set records a new position of a file without persisting it, see Save
+func (state *State) Set(file string, position Position) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.files[file] = position
+}
+
+// Save persists the state
+func (state *State) Save() error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	return state.save()
+}
+
+// save writes the state atomically through a temporary file
+func (state *State) save() error {
+	data, err := json.MarshalIndent(state.files, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(state.path), filepath.Base(state.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), state.path)
+}
@@ -0,0 +1,154 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Algorithm identifies the compression of a payload, recorded in the payload header
+type Algorithm byte
+
+// Supported compression algorithms
+const (
+	None Algorithm = 0
+	Gzip Algorithm = 1
+	Zstd Algorithm = 2
+)
+
+// MaxDecompressedSize bound on a decompressed payload, payloads read back from the chain are untrusted
+const MaxDecompressedSize = 64 << 20
+
+// ErrTooLarge the payload decompresses to more than MaxDecompressedSize
+var ErrTooLarge = errors.New("decompressed payload exceeds the size limit")
+
+// names of the algorithms as used in config
+var names = map[Algorithm]string{None: "none", Gzip: "gzip", Zstd: "zstd"}
+
+// String name of the algorithm
+func (algorithm Algorithm) String() string {
+	if name, ok := names[algorithm]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", byte(algorithm))
+}
+
+// ParseAlgorithm looks an algorithm up by name, an empty name means none
+func ParseAlgorithm(name string) (Algorithm, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return None, nil
+	}
+	for algorithm, known := range names {
+		if known == name {
+			return algorithm, nil
+		}
+	}
+	return None, fmt.Errorf("unknown compression %q (expected none, gzip or zstd)", name)
+}
+
+// Codec compresses payloads with one algorithm and decompresses payloads of any algorithm
+//
+// A zstd dictionary trained on the tailed logs, for instance with
+// `zstd --train access.log.* -o nginx.dict`, shrinks short batches of repetitive lines
+// considerably. Decoders need the same dictionary, zstd frames carry its ID. A dictionary
+// for nginx access logs ships in dictionaries/nginx.dict and is used by default
+type Codec struct {
+	algorithm Algorithm
+	encoder   *zstd.Encoder
+	decoder   *zstd.Decoder
+}
+
+// New creates a codec compressing with algorithm, dictionary is optional and only used by zstd
+func New(algorithm Algorithm, dictionary []byte) (*Codec, error) {
+	if _, ok := names[algorithm]; !ok {
+		return nil, fmt.Errorf("unknown compression %s", algorithm)
+	}
+	var encoderOptions []zstd.EOption
+	decoderOptions := []zstd.DOption{zstd.WithDecoderMaxMemory(MaxDecompressedSize)}
+	if len(dictionary) > 0 {
+		encoderOptions = append(encoderOptions, zstd.WithEncoderDict(dictionary))
+		decoderOptions = append(decoderOptions, zstd.WithDecoderDicts(dictionary))
+	}
+	encoder, err := zstd.NewWriter(nil, append(encoderOptions, zstd.WithEncoderLevel(zstd.SpeedBestCompression))...)
+	if err != nil {
+		return nil, fmt.Errorf("zstd dictionary: %v", err)
+	}
+	decoder, err := zstd.NewReader(nil, decoderOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("zstd dictionary: %v", err)
+	}
+	return &Codec{algorithm: algorithm, encoder: encoder, decoder: decoder}, nil
+}
+
+// LoadDictionary reads a zstd dictionary file, an empty path means no dictionary
+func LoadDictionary(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(path)
+}
+
+// Algorithm the algorithm payloads are compressed with
+func (codec *Codec) Algorithm() Algorithm {
+	if codec == nil {
+		return None
+	}
+	return codec.algorithm
+}
+
+// Compress compresses data with the codec's algorithm, a nil codec leaves data as is
+func (codec *Codec) Compress(data []byte) ([]byte, error) {
+	switch codec.Algorithm() {
+	case Gzip:
+		var buffer bytes.Buffer
+		writer, _ := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	case Zstd:
+		return codec.encoder.EncodeAll(data, nil), nil
+	default:
+		return data, nil
+	}
+}
+
+// Decompress reverses the compression of data with algorithm, up to MaxDecompressedSize bytes
+func (codec *Codec) Decompress(algorithm Algorithm, data []byte) ([]byte, error) {
+	switch algorithm {
+	case None:
+		return data, nil
+	case Gzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		decompressed, err := ioutil.ReadAll(io.LimitReader(reader, MaxDecompressedSize+1))
+		if err == nil && len(decompressed) > MaxDecompressedSize {
+			return nil, ErrTooLarge
+		}
+		return decompressed, err
+	case Zstd:
+		if codec == nil {
+			return nil, fmt.Errorf("no zstd decoder configured")
+		}
+		decompressed, err := codec.decoder.DecodeAll(data, nil)
+		if err == zstd.ErrDecoderSizeExceeded {
+			return nil, ErrTooLarge
+		}
+		return decompressed, err
+	default:
+		return nil, fmt.Errorf("unknown compression %s", algorithm)
+	}
+}
@@ -0,0 +1,76 @@
+package compression
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// batch a few nginx lines as they are encoded before sealing
+var batch = []byte(strings.Repeat(`{"source":"nginx","text":"10.44.222.108 - - [03/Dec/2021:07:05:35 +0000] \"GET / HTTP/1.1\" 200 2128 \"-\" \"curl/7.68.0\""}`, 4))
+
+// TestRoundTrip Every algorithm decompresses back to the original batch
+func TestRoundTrip(t *testing.T) {
+	dictionary, err := ioutil.ReadFile("../dictionaries/nginx.dict")
+	if err != nil {
+		t.Fatalf("Failed to read dictionary: %v", err)
+	}
+	sizes := map[string]int{}
+	for _, name := range []string{"none", "gzip", "zstd", "zstd+dict"} {
+		algorithm, err := ParseAlgorithm(strings.TrimSuffix(name, "+dict"))
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+		var codec *Codec
+		if strings.HasSuffix(name, "+dict") {
+			codec, err = New(algorithm, dictionary)
+		} else {
+			codec, err = New(algorithm, nil)
+		}
+		if err != nil {
+			t.Fatalf("Failed to create %s codec: %v", name, err)
+		}
+		compressed, err := codec.Compress(batch)
+		if err != nil {
+			t.Fatalf("Failed to compress with %s: %v", name, err)
+		}
+		sizes[name] = len(compressed)
+		decompressed, err := codec.Decompress(algorithm, compressed)
+		if err != nil {
+			t.Fatalf("Failed to decompress with %s: %v", name, err)
+		}
+		if !bytes.Equal(decompressed, batch) {
+			t.Errorf("Expected %s to round trip, received %q", name, decompressed)
+		}
+	}
+	if sizes["gzip"] >= sizes["none"] || sizes["zstd"] >= sizes["none"] || sizes["zstd+dict"] >= sizes["zstd"] {
+		t.Errorf("Expected compression to shrink the batch and the dictionary to help, sizes %v", sizes)
+	}
+
+	// Payloads decompressing beyond the limit are rejected rather than read into memory
+	bomb := make([]byte, MaxDecompressedSize+1)
+	for _, algorithm := range []Algorithm{Gzip, Zstd} {
+		codec, err := New(algorithm, nil)
+		if err != nil {
+			t.Fatalf("Failed to create %s codec: %v", algorithm, err)
+		}
+		compressed, err := codec.Compress(bomb)
+		if err != nil {
+			t.Fatalf("Failed to compress with %s: %v", algorithm, err)
+		}
+		if _, err := codec.Decompress(algorithm, compressed); err != ErrTooLarge {
+			t.Errorf("Expected %s to reject an oversized payload, received %v", algorithm, err)
+		}
+	}
+}
+
+// TestParseAlgorithm Unknown names are rejected
+func TestParseAlgorithm(t *testing.T) {
+	if algorithm, err := ParseAlgorithm(""); err != nil || algorithm != None {
+		t.Errorf("Expected no compression by default, received %s: %v", algorithm, err)
+	}
+	if _, err := ParseAlgorithm("brotli"); err == nil {
+		t.Error("Expected an unknown algorithm to be rejected")
+	}
+}
@@ -4,30 +4,78 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/TheLazarusNetwork/Monitor/utility"
-
 	"github.com/textileio/go-threads/common"
 	"github.com/textileio/go-threads/core/thread"
 	"github.com/textileio/go-threads/db"
 	"github.com/textileio/go-threads/util"
 )
 
-// CreateDB for creating a Thread DB
-func CreateDB() (*db.DB, func()) {
-	dir, err := ioutil.TempDir("./", "logs")
-	utility.CheckError(err)
-	n, err := common.DefaultNetwork(dir, common.WithNetDebug(true), common.WithNetHostAddr(util.FreeLocalAddr()))
-	utility.CheckError(err)
-	id := thread.NewIDV1(thread.Raw, 32)
-	d, err := db.NewDB(context.Background(), n, id)
-	utility.CheckError(err)
+// threadFile name of the file in the repo holding the ID of the thread the DB lives in
+const threadFile = "thread.id"
+
+// CreateDB for opening the Thread DB kept in repo, it is created on first use and reopened on every later start.
+// The thread is the configured one, or else the one saved in the repo, so the logs of earlier runs stay in the same DB
+func CreateDB(repo string, configured string) (*db.DB, func(), error) {
+	repo, err := expandHome(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(repo, 0700); err != nil {
+		return nil, nil, err
+	}
+	id, err := threadID(repo, configured)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := common.DefaultNetwork(repo, common.WithNetDebug(true), common.WithNetHostAddr(util.FreeLocalAddr()))
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := db.NewDB(context.Background(), n, id, db.WithNewRepoPath(repo))
+	if err != nil {
+		n.Close()
+		return nil, nil, err
+	}
 	return d, func() {
 		time.Sleep(time.Second) // Give threads a chance to finish work
 		if err := n.Close(); err != nil {
 			panic(err)
 		}
-		_ = os.RemoveAll(dir)
+	}, nil
+}
+
+// threadID the configured thread, else the one saved in repo, else a new one that is saved for the next start
+func threadID(repo string, configured string) (thread.ID, error) {
+	if configured != "" {
+		return thread.Decode(configured)
+	}
+	path := filepath.Join(repo, threadFile)
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		return thread.Decode(strings.TrimSpace(string(data)))
+	}
+	if !os.IsNotExist(err) {
+		return thread.Undef, err
+	}
+	id := thread.NewIDV1(thread.Raw, 32)
+	if err := ioutil.WriteFile(path, []byte(id.String()+"\n"), 0600); err != nil {
+		return thread.Undef, err
+	}
+	return id, nil
+}
+
+// expandHome resolves a leading ~ the way the shell would, as paths like ~/textile are common in config
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
 }
@@ -1 +1,28 @@
 package datastore
+
+import (
+	"github.com/TheLazarusNetwork/Monitor/model"
+
+	core "github.com/textileio/go-threads/core/db"
+	"github.com/textileio/go-threads/db"
+	"github.com/textileio/go-threads/util"
+)
+
+// LogCollection name of the collection holding the logs
+const LogCollection = "Logs"
+
+// CreateCollection for creating the Logs collection in a Thread DB
+func CreateCollection(d *db.DB) (*db.Collection, error) {
+	if collection := d.GetCollection(LogCollection); collection != nil {
+		return collection, nil
+	}
+	return d.NewCollection(db.CollectionConfig{
+		Name:   LogCollection,
+		Schema: util.SchemaFromInstance(&model.Log{}, false),
+	})
+}
+
+// CreateLog for storing a log in the Logs collection
+func CreateLog(collection *db.Collection, entry *model.Log) (core.InstanceID, error) {
+	return collection.Create(util.JSONFromInstance(entry))
+}
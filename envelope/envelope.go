@@ -0,0 +1,311 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/TheLazarusNetwork/Monitor/compression"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+// DataKeySize size of the random AES-256 key encrypting a payload
+const DataKeySize = 32
+
+// Version current version of the binary payload format, version 1 payloads have no compression byte
+// and only version 3 payloads authenticate their header along with the ciphertext
+const Version = 3
+
+// AlgorithmECIESAESGCM data key wrapped with ECIES over secp256k1, payload encrypted with AES-256-GCM
+const AlgorithmECIESAESGCM = 1
+
+// ErrNotRecipient the private key is not one of the envelope's recipients
+var ErrNotRecipient = errors.New("envelope: not a recipient")
+
+// Envelope a payload encrypted with a random data key, the data key wrapped for every recipient
+//
+// The binary encoding is
+//
+//	version (1) | algorithm (1) | compression (1) | key count (1) | keys | nonce (12) | ciphertext
+//
+// where every key is its key ID (8), the wrapped key length (2, big endian) and the wrapped key.
+// Everything before the nonce is the header, passed to AES-GCM as additional data
+type Envelope struct {
+	Version     byte
+	Algorithm   byte
+	Compression compression.Algorithm // Compression of the plaintext applied before sealing
+	Keys        []WrappedKey
+	Nonce       []byte
+	Ciphertext  []byte
+}
+
+// WrappedKey the data key encrypted with ECIES to one recipient
+type WrappedKey struct {
+	KeyID KeyID
+	Key   []byte
+}
+
+// KeyID short identifier of a recipient's public key, the first bytes of its Ethereum address
+type KeyID [8]byte
+
+// KeyIDOf computes the key ID of a public key
+func KeyIDOf(publicKey *ecies.PublicKey) KeyID {
+	return KeyIDOfAddress(crypto.PubkeyToAddress(*publicKey.ExportECDSA()))
+}
+
+// KeyIDOfAddress computes the key ID of the public key behind an address
+func KeyIDOfAddress(address common.Address) KeyID {
+	var id KeyID
+	copy(id[:], address.Bytes())
+	return id
+}
+
+// String hex form of the key ID
+func (id KeyID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Encode serialises the envelope into its binary form
+func (envelope *Envelope) Encode() []byte {
+	buffer := bytes.NewBuffer(envelope.header())
+	buffer.Write(envelope.Nonce)
+	buffer.Write(envelope.Ciphertext)
+	return buffer.Bytes()
+}
+
+// header the binary form up to the nonce
+func (envelope *Envelope) header() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteByte(envelope.Version)
+	buffer.WriteByte(envelope.Algorithm)
+	if envelope.Version >= 2 {
+		buffer.WriteByte(byte(envelope.Compression))
+	}
+	buffer.WriteByte(byte(len(envelope.Keys)))
+	for _, wrapped := range envelope.Keys {
+		buffer.Write(wrapped.KeyID[:])
+		binary.Write(&buffer, binary.BigEndian, uint16(len(wrapped.Key)))
+		buffer.Write(wrapped.Key)
+	}
+	return buffer.Bytes()
+}
+
+// additionalData what AES-GCM authenticates besides the ciphertext, nothing before version 3
+func (envelope *Envelope) additionalData() []byte {
+	if envelope.Version < 3 {
+		return nil
+	}
+	return envelope.header()
+}
+
+// Decode parses the binary form of an envelope
+func Decode(data []byte) (*Envelope, error) {
+	reader := bytes.NewReader(data)
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, errMalformed(err)
+	}
+	envelope := &Envelope{Version: header[0], Algorithm: header[1]}
+	if envelope.Version < 1 || envelope.Version > Version {
+		return nil, fmt.Errorf("envelope: unsupported version %d", envelope.Version)
+	}
+	if envelope.Algorithm != AlgorithmECIESAESGCM {
+		return nil, fmt.Errorf("envelope: unsupported algorithm %d", envelope.Algorithm)
+	}
+	if envelope.Version >= 2 {
+		algorithm, err := reader.ReadByte()
+		if err != nil {
+			return nil, errMalformed(err)
+		}
+		envelope.Compression = compression.Algorithm(algorithm)
+	}
+	count, err := reader.ReadByte()
+	if err != nil {
+		return nil, errMalformed(err)
+	}
+	for i := 0; i < int(count); i++ {
+		var wrapped WrappedKey
+		var size uint16
+		if _, err := io.ReadFull(reader, wrapped.KeyID[:]); err != nil {
+			return nil, errMalformed(err)
+		}
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return nil, errMalformed(err)
+		}
+		wrapped.Key = make([]byte, size)
+		if _, err := io.ReadFull(reader, wrapped.Key); err != nil {
+			return nil, errMalformed(err)
+		}
+		envelope.Keys = append(envelope.Keys, wrapped)
+	}
+	envelope.Nonce = make([]byte, nonceSize)
+	if _, err := io.ReadFull(reader, envelope.Nonce); err != nil {
+		return nil, errMalformed(err)
+	}
+	envelope.Ciphertext = data[len(data)-reader.Len():]
+	return envelope, nil
+}
+
+// Seal encrypts plaintext, already compressed with compressed, with AES-GCM under a fresh data key and wraps that key for each recipient
+func Seal(plaintext []byte, compressed compression.Algorithm, recipients []*ecies.PublicKey) ([]byte, error) {
+	if len(recipients) == 0 || len(recipients) > 255 {
+		return nil, fmt.Errorf("envelope: %d recipients, expected 1 to 255", len(recipients))
+	}
+	dataKey := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	envelope := &Envelope{Version: Version, Algorithm: AlgorithmECIESAESGCM, Compression: compressed, Nonce: make([]byte, nonceSize)}
+	if _, err := io.ReadFull(rand.Reader, envelope.Nonce); err != nil {
+		return nil, err
+	}
+	for _, recipient := range recipients {
+		wrapped, err := ecies.Encrypt(rand.Reader, recipient, dataKey, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Keys = append(envelope.Keys, WrappedKey{KeyID: KeyIDOf(recipient), Key: wrapped})
+	}
+	// The header is authenticated so that its compression and recipients cannot be swapped
+	envelope.Ciphertext = aead.Seal(nil, envelope.Nonce, plaintext, envelope.additionalData())
+	return envelope.Encode(), nil
+}
+
+// Keys looks private keys up by key ID, such as a keyring holding current and retired keys
+type Keys interface {
+	Key(id KeyID) (*ecies.PrivateKey, bool)
+}
+
+// single the keys of one private key
+type single struct {
+	privateKey *ecies.PrivateKey
+}
+
+// Key the private key when its ID matches
+func (keys single) Key(id KeyID) (*ecies.PrivateKey, bool) {
+	return keys.privateKey, KeyIDOf(&keys.privateKey.PublicKey) == id
+}
+
+// KeysOf the keys of a single private key
+func KeysOf(privateKey *ecies.PrivateKey) Keys {
+	return single{privateKey}
+}
+
+// KeyList looks a key ID up in every Keys in turn
+type KeyList []Keys
+
+// Key the first private key found for the ID
+func (list KeyList) Key(id KeyID) (*ecies.PrivateKey, bool) {
+	for _, keys := range list {
+		if privateKey, ok := keys.Key(id); ok {
+			return privateKey, true
+		}
+	}
+	return nil, false
+}
+
+// Open decrypts an envelope with the private key of any one of its recipients and decompresses it with codec
+func Open(data []byte, privateKey *ecies.PrivateKey, codec *compression.Codec) ([]byte, error) {
+	return OpenWith(data, single{privateKey}, codec)
+}
+
+// OpenWith decrypts an envelope with the first of its recipients' keys found in keys that opens it and decompresses it with codec
+func OpenWith(data []byte, keys Keys, codec *compression.Codec) ([]byte, error) {
+	envelope, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	// Key IDs are short and not authenticated on their own, a key that fails moves on to the next recipient
+	failed := ErrNotRecipient
+	for _, wrapped := range envelope.Keys {
+		privateKey, ok := keys.Key(wrapped.KeyID)
+		if !ok {
+			continue
+		}
+		plaintext, err := envelope.open(privateKey, wrapped)
+		if err != nil {
+			failed = err
+			continue
+		}
+		return codec.Decompress(envelope.Compression, plaintext)
+	}
+	return nil, failed
+}
+
+// open unwraps the data key for one recipient and decrypts the ciphertext with it
+func (envelope *Envelope) open(privateKey *ecies.PrivateKey, wrapped WrappedKey) ([]byte, error) {
+	dataKey, err := privateKey.Decrypt(wrapped.Key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, envelope.Nonce, envelope.Ciphertext, envelope.additionalData())
+}
+
+// ParseRecipient reads a hex encoded secp256k1 public key, compressed, uncompressed or without the 0x04 prefix
+func ParseRecipient(value string) (*ecies.PublicKey, error) {
+	raw, err := hexutil.Decode(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("recipient %q: %v", value, err)
+	}
+	if len(raw) == 64 {
+		raw = append([]byte{4}, raw...)
+	}
+	publicKey, err := crypto.UnmarshalPubkey(raw)
+	if err != nil && len(raw) == 33 {
+		publicKey, err = crypto.DecompressPubkey(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("recipient %q: %v", value, err)
+	}
+	return ecies.ImportECDSAPublic(publicKey), nil
+}
+
+// ParseRecipients reads a comma separated list of recipient public keys
+func ParseRecipients(values string) ([]*ecies.PublicKey, error) {
+	var recipients []*ecies.PublicKey
+	for _, value := range strings.Split(values, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		recipient, err := ParseRecipient(value)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// nonceSize size of the AES-GCM nonce
+const nonceSize = 12
+
+func errMalformed(err error) error {
+	return fmt.Errorf("envelope: malformed payload: %v", err)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
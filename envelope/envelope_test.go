@@ -0,0 +1,129 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/TheLazarusNetwork/Monitor/compression"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+// generate a fresh recipient key
+func generate(t *testing.T) *ecies.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return ecies.ImportECDSA(key)
+}
+
+// TestSealOpen Every recipient opens the envelope, anyone else is refused
+func TestSealOpen(t *testing.T) {
+	agent, security, auditor, outsider := generate(t), generate(t), generate(t), generate(t)
+	plaintext := []byte(`[{"text":"GET / 200"}]`)
+
+	data, err := Seal(plaintext, compression.None, []*ecies.PublicKey{&agent.PublicKey, &security.PublicKey, &auditor.PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to seal envelope: %v", err)
+	}
+	for _, recipient := range []*ecies.PrivateKey{agent, security, auditor} {
+		opened, err := Open(data, recipient, nil)
+		if err != nil {
+			t.Fatalf("Failed to open envelope: %v", err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Errorf("Expected %q, opened %q", plaintext, opened)
+		}
+	}
+	if _, err := Open(data, outsider, nil); err != ErrNotRecipient {
+		t.Errorf("Expected the outsider to be refused, received %v", err)
+	}
+
+	envelope, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Failed to decode envelope: %v", err)
+	}
+	if len(envelope.Keys) != 3 || envelope.Keys[1].KeyID != KeyIDOf(&security.PublicKey) {
+		t.Errorf("Expected the wrapped keys in recipient order, decoded %v", envelope.Keys)
+	}
+	if !bytes.Equal(envelope.Encode(), data) {
+		t.Error("Expected the encoding to round trip")
+	}
+	if _, err := Decode(data[:40]); err == nil {
+		t.Error("Expected a truncated envelope to be rejected")
+	}
+
+	// The header is authenticated, a changed compression byte no longer opens
+	tampered := append([]byte{}, data...)
+	tampered[2] = byte(compression.Gzip)
+	if _, err := Open(tampered, agent, nil); err == nil {
+		t.Error("Expected a changed header to be rejected")
+	}
+
+	// A wrapped key under a colliding key ID does not keep the recipient from its own entry, version 2 has no additional data
+	for _, version := range []byte{2, Version} {
+		dataKey := make([]byte, DataKeySize)
+		forAgent, _ := ecies.Encrypt(rand.Reader, &agent.PublicKey, dataKey, nil, nil)
+		forAuditor, _ := ecies.Encrypt(rand.Reader, &auditor.PublicKey, dataKey, nil, nil)
+		colliding := &Envelope{Version: version, Algorithm: AlgorithmECIESAESGCM, Nonce: make([]byte, nonceSize), Keys: []WrappedKey{
+			{KeyID: KeyIDOf(&auditor.PublicKey), Key: forAgent},
+			{KeyID: KeyIDOf(&auditor.PublicKey), Key: forAuditor},
+		}}
+		aead, _ := newAEAD(dataKey)
+		colliding.Ciphertext = aead.Seal(nil, colliding.Nonce, plaintext, colliding.additionalData())
+		if opened, err := Open(colliding.Encode(), auditor, nil); err != nil || !bytes.Equal(opened, plaintext) {
+			t.Errorf("Expected the auditor to open the version %d envelope with its own entry, received %q (%v)", version, opened, err)
+		}
+	}
+}
+
+// TestCompressed The compression recorded in the header is undone on open
+func TestCompressed(t *testing.T) {
+	agent := generate(t)
+	codec, err := compression.New(compression.Gzip, nil)
+	if err != nil {
+		t.Fatalf("Failed to create codec: %v", err)
+	}
+	plaintext := bytes.Repeat([]byte(`{"text":"GET / 200"}`), 10)
+	compressed, err := codec.Compress(plaintext)
+	if err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	data, err := Seal(compressed, codec.Algorithm(), []*ecies.PublicKey{&agent.PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to seal envelope: %v", err)
+	}
+	opened, err := Open(data, agent, codec)
+	if err != nil {
+		t.Fatalf("Failed to open envelope: %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Expected %q, opened %q", plaintext, opened)
+	}
+}
+
+// TestParseRecipient Public keys are accepted in all their hex forms
+func TestParseRecipient(t *testing.T) {
+	key := generate(t).PublicKey.ExportECDSA()
+	uncompressed := crypto.FromECDSAPub(key)
+	for _, value := range []string{
+		hexutil.Encode(uncompressed),
+		hexutil.Encode(uncompressed[1:]),
+		hexutil.Encode(crypto.CompressPubkey(key)),
+	} {
+		recipient, err := ParseRecipient(value)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", value, err)
+		}
+		if KeyIDOf(recipient) != KeyIDOf(ecies.ImportECDSAPublic(key)) {
+			t.Errorf("Expected %s to parse to the same key", value)
+		}
+	}
+	if _, err := ParseRecipient("0x1234"); err == nil {
+		t.Error("Expected a short key to be rejected")
+	}
+}
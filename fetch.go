@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/audit"
+	"github.com/TheLazarusNetwork/Monitor/compression"
+	"github.com/TheLazarusNetwork/Monitor/envelope"
+	"github.com/TheLazarusNetwork/Monitor/keyring"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/utility"
+	"github.com/TheLazarusNetwork/Monitor/wallet"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/ethereum/go-ethereum/ethclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// fetch reads the Log events of the agents over a block range and prints the decrypted lines
+//
+//	monitor fetch [-sender 0x..,0x..] [-from 0] [-to latest] [-chunk 5000] [-keyring ./keyring.json] [-key key.hex] [-keystore key.json] [-format text|json]
+func fetch(args []string) {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	senders := flags.String("sender", "", "comma separated agent addresses, the configured agent by default")
+	from := flags.Uint64("from", 0, "first block to read")
+	to := flags.Uint64("to", 0, "last block to read, 0 for the latest block")
+	chunk := flags.Uint64("chunk", audit.DefaultChunk, "blocks queried per request, within the provider's eth_getLogs limit")
+	keys := keyFlags(flags)
+	format := flags.String("format", "text", "output format, text or json")
+	flags.Parse(args)
+
+	addresses, err := parseSenders(*senders)
+	utility.CheckError("Error in reading senders:", err)
+	decoder, err := keys.decoder()
+	utility.CheckError("Error in loading decryption keys:", err)
+	printer, err := newEntryPrinter(os.Stdout, *format)
+	utility.CheckError("Error in configuring output:", err)
+
+	client, err := ethclient.Dial(viper.Get("INFURA_ENDPOINT").(string))
+	utility.CheckError("Error in connecting to Infura EndPoint:", err)
+	defer client.Close()
+	ctx := context.Background()
+	if *to == 0 {
+		*to, err = client.BlockNumber(ctx)
+		utility.CheckError("Error in fetching latest block:", err)
+	}
+	filterer, err := logger.NewLoggerFilterer(common.HexToAddress(viper.Get("LOGGER_CONTRACT_ADDRESS").(string)), client)
+	utility.CheckError("Unable to load instance of the deployed contract:", err)
+
+	timestamps := audit.NewTimestamps(client)
+	events := 0
+	err = audit.Walk(ctx, filterer, addresses, *from, *to, *chunk, func(event *logger.LoggerLog) error {
+		entry := decoder.Decode(event)
+		entry.Time, err = timestamps.Of(ctx, entry.Block)
+		if err != nil {
+			return err
+		}
+		events++
+		return printer.Print(entry)
+	})
+	utility.CheckError("Error in reading Log events:", err)
+	log.Infof("Fetched %d events of blocks %d to %d", events, *from, *to)
+}
+
+// decryptionKeys where the keys opening fetched payloads come from
+type decryptionKeys struct {
+	keyring  *string
+	keyFile  *string
+	keystore *string
+}
+
+// keyFlags registers the key source flags of the commands reading payloads back
+func keyFlags(flags *flag.FlagSet) decryptionKeys {
+	return decryptionKeys{
+		keyring:  flags.String("keyring", viper.GetString("KEYRING_FILE"), "keyring of the agent, holding current and retired encryption keys"),
+		keyFile:  flags.String("key", "", "file holding the hex encoded private key of a recipient"),
+		keystore: flags.String("keystore", "", "encrypted keystore of a recipient, unlocked with KEYSTORE_PASSPHRASE_FILE, KEYSTORE_PASSPHRASE_ENV or a prompt"),
+	}
+}
+
+// decoder collects the keyring, the recipient key and the mnemonic's encryption key into a decoder, baseline
+// entries are tried with the recipient key and the mnemonic's signing key
+func (keys decryptionKeys) decoder() (*audit.Decoder, error) {
+	var list envelope.KeyList
+	// Baseline entries were encrypted with the wallet key, which is the signing key now
+	var legacy []*ecies.PrivateKey
+	if *keys.keyring != "" {
+		ring, err := keyring.Open(*keys.keyring)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, ring)
+	}
+	if *keys.keyFile != "" || *keys.keystore != "" {
+		privateKey, source, err := wallet.KeySource{
+			Keystore:       *keys.keystore,
+			PassphraseFile: viper.GetString("KEYSTORE_PASSPHRASE_FILE"),
+			PassphraseEnv:  viper.GetString("KEYSTORE_PASSPHRASE_ENV"),
+			KeyFile:        *keys.keyFile,
+		}.Load()
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("Decryption Key: %s from %s", envelope.KeyIDOfAddress(crypto.PubkeyToAddress(privateKey.PublicKey)), source)
+		list = append(list, envelope.KeysOf(ecies.ImportECDSA(privateKey)))
+		legacy = append(legacy, ecies.ImportECDSA(privateKey))
+	}
+	if viper.GetString("MNEMONIC") != "" {
+		privateKey, _, _, err := deriveKey("ENCRYPTION_KEY_PATH")
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, envelope.KeysOf(ecies.ImportECDSA(privateKey)))
+		signingKey, _, _, err := deriveKey("SIGNING_KEY_PATH")
+		if err != nil {
+			return nil, err
+		}
+		legacy = append(legacy, ecies.ImportECDSA(signingKey))
+	}
+
+	dictionary, err := compression.LoadDictionary(viper.GetString("COMPRESSION_DICTIONARY"))
+	if err != nil {
+		return nil, err
+	}
+	codec, err := compression.New(compression.Zstd, dictionary)
+	if err != nil {
+		return nil, err
+	}
+	return &audit.Decoder{Keys: list, Codec: codec, Legacy: legacy}, nil
+}
+
+// entryPrinter writes decoded entries as one text or JSON line per log line
+type entryPrinter struct {
+	out  io.Writer
+	json bool
+}
+
+// printedLine the JSON form of a fetched log line
+type printedLine struct {
+	Block     uint64         `json:"block"`
+	TxHash    common.Hash    `json:"tx"`
+	Timestamp time.Time      `json:"timestamp"`
+	Sender    common.Address `json:"sender"`
+	Source    string         `json:"source,omitempty"`
+	Sequence  uint64         `json:"sequence,omitempty"`
+	Root      *common.Hash   `json:"root,omitempty"`
+	File      string         `json:"file,omitempty"`
+	Time      *time.Time     `json:"time,omitempty"`
+	Text      string         `json:"text,omitempty"`
+	Count     int            `json:"count,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// newEntryPrinter creates a printer for the text or json format
+func newEntryPrinter(out io.Writer, format string) (*entryPrinter, error) {
+	switch format {
+	case "text":
+		return &entryPrinter{out: out}, nil
+	case "json":
+		return &entryPrinter{out: out, json: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected text or json", format)
+	}
+}
+
+// Print writes every line of the entry, or a single line saying why it could not be read
+func (printer *entryPrinter) Print(entry *audit.Entry) error {
+	header := printedLine{Block: entry.Block, TxHash: entry.TxHash, Timestamp: entry.Time, Sender: entry.Sender}
+	if entry.Anchor != nil {
+		header.Source, header.Sequence, header.Root = entry.Anchor.Source, entry.Anchor.Sequence, &entry.Anchor.Root
+	}
+	if entry.Err != nil {
+		header.Error = entry.Err.Error()
+		if entry.Anchor != nil {
+			header.Count = entry.Anchor.Count
+		}
+		return printer.write(header)
+	}
+	for _, line := range entry.Lines {
+		printed := header
+		printed.Source, printed.File, printed.Text = line.Source, line.File, line.Text
+		if !line.Time.IsZero() {
+			lineTime := line.Time
+			printed.Time = &lineTime
+		}
+		if err := printer.write(printed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (printer *entryPrinter) write(line printedLine) error {
+	if printer.json {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(printer.out, "%s\n", data)
+		return err
+	}
+	prefix := fmt.Sprintf("%s block %d tx %s %s", line.Timestamp.Format(time.RFC3339), line.Block, line.TxHash.Hex(), line.Source)
+	if line.Sequence != 0 {
+		prefix += fmt.Sprintf(" #%d", line.Sequence)
+	}
+	if line.Error != "" {
+		_, err := fmt.Fprintf(printer.out, "%s | %d lines not readable: %s\n", prefix, line.Count, line.Error)
+		return err
+	}
+	_, err := fmt.Fprintf(printer.out, "%s | %s\n", prefix, line.Text)
+	return err
+}
@@ -0,0 +1,233 @@
+package keyring
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/envelope"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+// Key one encryption key of the agent, current until it is retired
+type Key struct {
+	ID         envelope.KeyID
+	PrivateKey *ecies.PrivateKey
+	Created    time.Time
+	Retired    time.Time // Zero while the key is current
+}
+
+// record a key as stored in the keyring file
+type record struct {
+	ID         string     `json:"id"`
+	PrivateKey string     `json:"private_key"`
+	Created    time.Time  `json:"created"`
+	Retired    *time.Time `json:"retired,omitempty"`
+}
+
+// Keyring the agent's current encryption key and every retired one, persisted in a local file
+// readable by its owner only, so payloads sealed under retired keys can still be opened
+type Keyring struct {
+	path     string
+	mutex    sync.Mutex
+	keys     []*Key
+	modified time.Time
+}
+
+// Open reads the keyring file, a missing file yields an empty keyring
+func Open(path string) (*Keyring, error) {
+	keyring := &Keyring{path: path}
+	if err := keyring.load(); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+// Current the key new payloads are sealed to, nil for an empty keyring
+func (keyring *Keyring) Current() *Key {
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	return keyring.current()
+}
+
+// Key finds the private key with the given ID, current or retired
+func (keyring *Keyring) Key(id envelope.KeyID) (*ecies.PrivateKey, bool) {
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	for _, key := range keyring.keys {
+		if key.ID == id {
+			return key.PrivateKey, true
+		}
+	}
+	return nil, false
+}
+
+// Use makes an existing key current, adding it when needed, and retires the previous one
+func (keyring *Keyring) Use(privateKey *ecdsa.PrivateKey) (*Key, error) {
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	key := newKey(privateKey)
+	for i, existing := range keyring.keys {
+		if existing.ID == key.ID {
+			keyring.keys = append(keyring.keys[:i], keyring.keys[i+1:]...)
+			break
+		}
+	}
+	if current := keyring.current(); current != nil {
+		current.Retired = key.Created
+	}
+	keyring.keys = append(keyring.keys, key)
+	return key, keyring.save()
+}
+
+// Rotate generates a new current key and retires the previous one
+func (keyring *Keyring) Rotate() (*Key, error) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	key := newKey(privateKey)
+	if current := keyring.current(); current != nil {
+		current.Retired = key.Created
+	}
+	keyring.keys = append(keyring.keys, key)
+	return key, keyring.save()
+}
+
+// Due tells if the current key is older than the rotation interval, a zero interval never rotates
+func (keyring *Keyring) Due(interval time.Duration) bool {
+	current := keyring.Current()
+	return interval > 0 && (current == nil || time.Since(current.Created) >= interval)
+}
+
+// Reload reads the keyring file again when another process, such as the key rotate command, changed it
+func (keyring *Keyring) Reload() error {
+	info, err := os.Stat(keyring.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	if info.ModTime().Equal(keyring.modified) {
+		return nil
+	}
+	return keyring.load()
+}
+
+// current the newest key not retired yet
+func (keyring *Keyring) current() *Key {
+	for i := len(keyring.keys) - 1; i >= 0; i-- {
+		if keyring.keys[i].Retired.IsZero() {
+			return keyring.keys[i]
+		}
+	}
+	return nil
+}
+
+// load replaces the keys with the content of the keyring file
+func (keyring *Keyring) load() error {
+	info, err := os.Stat(keyring.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(keyring.path)
+	if err != nil {
+		return err
+	}
+	var records []record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+	keys := make([]*Key, 0, len(records))
+	for _, record := range records {
+		raw, err := hexutil.Decode(record.PrivateKey)
+		if err != nil {
+			return err
+		}
+		privateKey, err := crypto.ToECDSA(raw)
+		if err != nil {
+			return err
+		}
+		key := newKey(privateKey)
+		if key.ID.String() != record.ID {
+			return errors.New("keyring: key " + record.ID + " does not match its private key")
+		}
+		key.Created = record.Created
+		if record.Retired != nil {
+			key.Retired = *record.Retired
+		}
+		keys = append(keys, key)
+	}
+	keyring.keys = keys
+	keyring.modified = info.ModTime()
+	return nil
+}
+
+// save writes the keyring atomically through a temporary file only its owner can read
+func (keyring *Keyring) save() error {
+	records := make([]record, len(keyring.keys))
+	for i, key := range keyring.keys {
+		records[i] = record{
+			ID:         key.ID.String(),
+			PrivateKey: hexutil.Encode(crypto.FromECDSA(key.PrivateKey.ExportECDSA())),
+			Created:    key.Created,
+		}
+		if !key.Retired.IsZero() {
+			retired := key.Retired
+			records[i].Retired = &retired
+		}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(keyring.path), filepath.Base(keyring.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), keyring.path); err != nil {
+		return err
+	}
+	info, err := os.Stat(keyring.path)
+	if err != nil {
+		return err
+	}
+	keyring.modified = info.ModTime()
+	return nil
+}
+
+// newKey wraps a private key as a new key created now
+func newKey(privateKey *ecdsa.PrivateKey) *Key {
+	eciesKey := ecies.ImportECDSA(privateKey)
+	return &Key{ID: envelope.KeyIDOf(&eciesKey.PublicKey), PrivateKey: eciesKey, Created: time.Now().UTC()}
+}
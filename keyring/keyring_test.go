@@ -0,0 +1,61 @@
+package keyring
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/TheLazarusNetwork/Monitor/compression"
+	"github.com/TheLazarusNetwork/Monitor/envelope"
+
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+// TestRotate Payloads sealed before a rotation still open with the retired key, also after reopening the keyring
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	keyring, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open keyring: %v", err)
+	}
+	if keyring.Current() != nil || !keyring.Due(1) {
+		t.Fatal("Expected an empty keyring to be due for rotation")
+	}
+	first, err := keyring.Rotate()
+	if err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	sealed, err := envelope.Seal([]byte("line"), compression.None, []*ecies.PublicKey{&first.PrivateKey.PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	second, err := keyring.Rotate()
+	if err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	if keyring.Current().ID != second.ID || first.Retired.IsZero() {
+		t.Errorf("Expected the new key to be current and the first retired")
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen keyring: %v", err)
+	}
+	if _, ok := reopened.Key(first.ID); !ok || reopened.Current().ID != second.ID {
+		t.Fatal("Expected both keys after reopening")
+	}
+	opened, err := envelope.OpenWith(sealed, reopened, nil)
+	if err != nil || string(opened) != "line" {
+		t.Errorf("Expected the retired key to open the payload, received %q: %v", opened, err)
+	}
+
+	// Another process rotating the file is picked up on reload
+	if _, err := reopened.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	if err := keyring.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if keyring.Current().ID != reopened.Current().ID {
+		t.Error("Expected the reloaded keyring to follow the rotation")
+	}
+}
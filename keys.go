@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/TheLazarusNetwork/Monitor/envelope"
+	"github.com/TheLazarusNetwork/Monitor/keyring"
+	"github.com/TheLazarusNetwork/Monitor/utility"
+	"github.com/TheLazarusNetwork/Monitor/wallet"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// key dispatches the key management commands, their results go to stdout and nothing secret is logged
+//
+//	monitor key generate [-words 24]
+//	monitor key list [-path m/44H/60H/0H/0] [-count 5]
+//	monitor key export [-path m/44H/60H/1H/0/0 | -keyring]
+//	monitor key rotate, or monitor rotate-key as before
+func key(args []string) {
+	if len(args) == 0 {
+		log.Fatal("key needs a command: generate, list, export or rotate")
+	}
+	switch args[0] {
+	case "generate":
+		generateMnemonic(args[1:])
+	case "list":
+		listKeys(args[1:])
+	case "export":
+		exportKey(args[1:])
+	case "rotate":
+		rotateKey(args[1:])
+	default:
+		log.Fatalf("Unknown key command: %s (expected generate, list, export or rotate)", args[0])
+	}
+}
+
+// generateMnemonic prints a new BIP-39 mnemonic for a new agent
+func generateMnemonic(args []string) {
+	flags := flag.NewFlagSet("key generate", flag.ExitOnError)
+	words := flags.Int("words", 24, "number of words: 12, 15, 18, 21 or 24")
+	flags.Parse(args)
+	if *words < 12 || *words > 24 || *words%3 != 0 {
+		log.Fatalf("A mnemonic has 12, 15, 18, 21 or 24 words, not %d", *words)
+	}
+
+	// Every three words carry 32 bits of entropy and one bit of checksum
+	entropy, err := bip39.NewEntropy(*words / 3 * 32)
+	utility.CheckError("Error in generating entropy:", err)
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	utility.CheckError("Error in generating mnemonic:", err)
+	fmt.Println(mnemonic)
+}
+
+// listKeys prints the address and public key of the first accounts under a path prefix of the configured mnemonic
+func listKeys(args []string) {
+	flags := flag.NewFlagSet("key list", flag.ExitOnError)
+	prefix := flags.String("path", "m/44H/60H/0H/0", "derivation path the account index is appended to")
+	count := flags.Int("count", 5, "number of accounts")
+	flags.Parse(args)
+
+	for index := 0; index < *count; index++ {
+		privateKey, publicKey, path, err := wallet.DeriveKey(viper.GetString("MNEMONIC"), viper.GetString("MNEMONIC_PASSPHRASE"), fmt.Sprintf("%s/%d", *prefix, index))
+		utility.CheckError("Error in computing Hierarchical Deterministic Wallet:", err)
+		fmt.Printf("%s\t%s\t%s\n", *path, crypto.PubkeyToAddress(privateKey.PublicKey).Hex(), hexutil.Encode(crypto.FromECDSAPub(publicKey)))
+	}
+}
+
+// exportKey prints an encryption public key in the form other agents accept in ENCRYPTION_RECIPIENTS
+func exportKey(args []string) {
+	flags := flag.NewFlagSet("key export", flag.ExitOnError)
+	path := flags.String("path", viper.GetString("ENCRYPTION_KEY_PATH"), "derivation path of the encryption key")
+	current := flags.Bool("keyring", false, "export the current key of the keyring instead, it changes on every rotation")
+	flags.Parse(args)
+
+	var publicKey *ecies.PublicKey
+	if *current {
+		ring, err := keyring.Open(viper.GetString("KEYRING_FILE"))
+		utility.CheckError("Error in opening keyring:", err)
+		if ring.Current() == nil {
+			log.Fatal("The keyring has no current key")
+		}
+		publicKey = &ring.Current().PrivateKey.PublicKey
+	} else {
+		_, ecdsaPublicKey, _, err := wallet.DeriveKey(viper.GetString("MNEMONIC"), viper.GetString("MNEMONIC_PASSPHRASE"), *path)
+		utility.CheckError("Error in deriving encryption key:", err)
+		publicKey = ecies.ImportECDSAPublic(ecdsaPublicKey)
+	}
+	fmt.Fprintf(os.Stderr, "Key ID: %s\n", envelope.KeyIDOf(publicKey))
+	fmt.Println(hexutil.Encode(crypto.FromECDSAPub(publicKey.ExportECDSA())))
+}
+
+// rotateKey retires the current encryption key and makes a new one current, a running agent picks it up with its next batch
+func rotateKey(args []string) {
+	flags := flag.NewFlagSet("key rotate", flag.ExitOnError)
+	flags.Parse(args)
+
+	ring, err := keyring.Open(viper.GetString("KEYRING_FILE"))
+	utility.CheckError("Error in opening keyring:", err)
+	key, err := ring.Rotate()
+	utility.CheckError("Error in rotating encryption key:", err)
+	log.Infof("Rotated Encryption Key: %s | Public Key: %s", key.ID, hexutil.Encode(crypto.FromECDSAPub(key.PrivateKey.PublicKey.ExportECDSA())))
+}
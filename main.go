@@ -2,24 +2,34 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
 	"math"
 	"math/big"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/ecies"
-	"github.com/ethereum/go-ethereum/ethclient"
 
-	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/checkpoint"
+	"github.com/TheLazarusNetwork/Monitor/compression"
+	"github.com/TheLazarusNetwork/Monitor/envelope"
+	"github.com/TheLazarusNetwork/Monitor/keyring"
+	"github.com/TheLazarusNetwork/Monitor/model"
+	"github.com/TheLazarusNetwork/Monitor/sink"
+	"github.com/TheLazarusNetwork/Monitor/spool"
+	"github.com/TheLazarusNetwork/Monitor/tailer"
+	"github.com/TheLazarusNetwork/Monitor/transactor"
 	"github.com/TheLazarusNetwork/Monitor/utility"
 	"github.com/TheLazarusNetwork/Monitor/wallet"
 
-	"github.com/nxadm/tail"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
@@ -35,8 +45,51 @@ func init() {
 	viper.SetConfigType("env")
 	viper.AutomaticEnv()
 
-	err := viper.ReadInConfig()
-	utility.CheckError("Error in reading config file:", err)
+	viper.SetDefault("LOG_SCAN_INTERVAL", "10s")
+	viper.SetDefault("CHECKPOINT_FILE", "./monitor.state")
+	viper.SetDefault("BATCH_SIZE", 100)
+	viper.SetDefault("BATCH_WINDOW", "30s")
+	viper.SetDefault("BATCH_DIR", "./batches")
+	viper.SetDefault("SPOOL_DIR", "./spool.d")
+	viper.SetDefault("SPOOL_SEGMENT_SIZE", 64<<20)
+	viper.SetDefault("SPOOL_REPORT_INTERVAL", "1m")
+	viper.SetDefault("RETRY_MIN_DELAY", "1s")
+	viper.SetDefault("RETRY_MAX_DELAY", "5m")
+	viper.SetDefault("FEE_STRATEGY", "oracle")
+	viper.SetDefault("FEE_PERCENTILE_BLOCKS", 5)
+	viper.SetDefault("FEE_PERCENTILE", 60)
+	viper.SetDefault("GAS_LIMIT_MARGIN", 20)
+	viper.SetDefault("CONFIRMATIONS", 12)
+	viper.SetDefault("RECEIPT_POLL_INTERVAL", "15s")
+	viper.SetDefault("DROP_TIMEOUT", "10m")
+	viper.SetDefault("STUCK_TIMEOUT", "5m")
+	viper.SetDefault("ANCHOR_PAYLOAD", true)
+	viper.SetDefault("SINKS", "chain")
+	viper.SetDefault("SINK_FILE_PATH", "./batches.log")
+	viper.SetDefault("REPO_PATH", "./textile")
+	viper.SetDefault("THREAD_ID", "")
+	viper.SetDefault("ENCRYPTION_RECIPIENTS", "")
+	viper.SetDefault("COMPRESSION", "zstd")
+	viper.SetDefault("COMPRESSION_DICTIONARY", "./dictionaries/nginx.dict")
+	viper.SetDefault("KEYRING_FILE", "./keyring.json")
+	viper.SetDefault("MNEMONIC", "")
+	viper.SetDefault("MNEMONIC_PASSPHRASE", "")
+	viper.SetDefault("KEYSTORE_FILE", "")
+	viper.SetDefault("KEYSTORE_PASSPHRASE_FILE", "")
+	viper.SetDefault("KEYSTORE_PASSPHRASE_ENV", "MONITOR_KEYSTORE_PASSPHRASE")
+	viper.SetDefault("PRIVATE_KEY_FILE", "")
+	viper.SetDefault("SIGNER_ENDPOINT", "")
+	viper.SetDefault("SIGNER_ADDRESS", "")
+	viper.SetDefault("SIGNING_KEY_PATH", wallet.DefaultPaths[wallet.Signing])
+	viper.SetDefault("ENCRYPTION_KEY_PATH", wallet.DefaultPaths[wallet.Encryption])
+	viper.SetDefault("KEY_ROTATION_INTERVAL", "720h")
+	viper.SetDefault("WATCH_ENDPOINT", "")
+
+	// Commands that work without a config, such as generating a new wallet, fail later if they need it
+	if err := viper.ReadInConfig(); err != nil {
+		log.Warnf("Error in reading config file: %v", err)
+		return
+	}
 	log.Infof("Reading Config File: %s", viper.ConfigFileUsed())
 }
 
@@ -44,100 +97,364 @@ func main() {
 
 	log.Infof("Lazarus Network Monitor Version: %s", utility.Version)
 
-	err := viper.ReadInConfig()
-	utility.CheckError("Error while reading config file:", err)
-
-	mnemonic := viper.Get("MNEMONIC").(string)
-	privateKey, publicKey, path, err := wallet.HDWallet(mnemonic)
-	utility.CheckError("Error in computing Hierarchical Deterministic Wallet:", err)
+	// The first argument selects a command, the monitor runs when there is none
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	// Key and proof commands read what they need from the environment when there is no config file
+	if command != "key" && command != "rotate-key" && command != "proof" {
+		err := viper.ReadInConfig()
+		if command == "verify" {
+			checkVerify("Error while reading config file:", err)
+		}
+		utility.CheckError("Error while reading config file:", err)
+	}
+	switch command {
+	case "run":
+		run()
+	case "cancel":
+		cancel(args)
+	case "key":
+		key(args)
+	case "rotate-key":
+		// Kept from before the key commands were grouped, same as key rotate
+		rotateKey(args)
+	case "audit":
+		auditChain(args)
+	case "fetch":
+		fetch(args)
+	case "watch":
+		watch(args)
+	case "verify":
+		verify(args)
+	case "proof":
+		proof(args)
+	default:
+		log.Fatalf("Unknown command: %s (expected run, cancel, key, rotate-key, audit, fetch, watch, verify or proof)", command)
+	}
+}
 
-	privateKeyBytes := crypto.FromECDSA(privateKey)
-	privateKeyHex := hexutil.Encode(privateKeyBytes) // hexutil.Encode(privateKeyBytes)[2:] for without 0x
-	publicKeyBytes := crypto.FromECDSAPub(publicKey)
-	publicKeyHex := hexutil.Encode(publicKeyBytes[1:]) // As Ethereum does not DER encode its public keys, public keys in Ethereum are only 64 bytes long
-	walletAddress := crypto.PubkeyToAddress(*publicKey).Hex()
+// run tails the configured logs and anchors them until the process is stopped
+func run() {
+	signer, keySource, err := newSigner()
+	utility.CheckError("Error in loading signing key:", err)
+	walletAddress := signer.Address().Hex()
 
-	// Display mnemonic and keys
-	log.Infof("Mnemonic: %s", mnemonic)
-	log.Infof("ETH Private Key: %s", privateKeyHex)
-	log.Infof("ETH Public Key: %s", publicKeyHex)
+	// Display public keys only, secrets never reach the logs
+	log.Infof("Signing Key: %s", keySource)
+	if keySigner, ok := signer.(*transactor.KeySigner); ok {
+		publicKeyBytes := crypto.FromECDSAPub(keySigner.PublicKey())
+		publicKeyHex := hexutil.Encode(publicKeyBytes[1:]) // As Ethereum does not DER encode its public keys, public keys in Ethereum are only 64 bytes long
+		log.Infof("ETH Public Key: %s", publicKeyHex)
+	}
 	log.Infof("ETH Wallet Address: %s", walletAddress)
-	log.Infof("Path: %s", *path)
 
-	// ECIES Encryption and Decryption
-	ecdsaPrivateKey, err := crypto.HexToECDSA(hexutil.Encode(privateKeyBytes)[2:])
-	eciesPrivateKey := ecies.ImportECDSA(ecdsaPrivateKey)
-	eciesPublicKey := eciesPrivateKey.PublicKey
+	// The agent's encryption key rotates, retired keys stay in the keyring to open older batches.
+	// An empty keyring starts from the mnemonic's encryption key
+	ring, err := keyring.Open(viper.GetString("KEYRING_FILE"))
+	utility.CheckError("Error in opening keyring:", err)
+	if ring.Current() == nil {
+		if viper.GetString("MNEMONIC") != "" {
+			// ECIES Encryption and Decryption use a key of their own, the signing key only signs transactions
+			encryptionKey, _, encryptionPath, err := deriveKey("ENCRYPTION_KEY_PATH")
+			utility.CheckError("Error in deriving encryption key:", err)
+			log.Infof("Encryption Path: %s", *encryptionPath)
+			_, err = ring.Use(encryptionKey)
+			utility.CheckError("Error in saving keyring:", err)
+		} else {
+			// Without a mnemonic the keyring starts with a random key
+			_, err = ring.Rotate()
+			utility.CheckError("Error in creating encryption key:", err)
+		}
+	}
+	rotation := viper.GetDuration("KEY_ROTATION_INTERVAL")
+	log.Infof("Encryption Key: %s", ring.Current().ID)
+
+	// Batches are readable by the agent and every configured recipient, such as the security team or an auditor
+	recipients, err := envelope.ParseRecipients(viper.GetString("ENCRYPTION_RECIPIENTS"))
+	utility.CheckError("Error in reading encryption recipients:", err)
+	for _, recipient := range recipients {
+		log.Infof("Encryption Recipient: %s", envelope.KeyIDOf(recipient))
+	}
+
+	// Batches are compressed before they are encrypted, ciphertext does not compress
+	compressionAlgorithm, err := compression.ParseAlgorithm(viper.GetString("COMPRESSION"))
+	utility.CheckError("Error in reading compression:", err)
+	dictionary, err := compression.LoadDictionary(viper.GetString("COMPRESSION_DICTIONARY"))
+	utility.CheckError("Error in reading compression dictionary:", err)
+	codec, err := compression.New(compressionAlgorithm, dictionary)
+	utility.CheckError("Error in configuring compression:", err)
 
 	infuraEndPoint := viper.Get("INFURA_ENDPOINT").(string)
-	client, err := ethclient.Dial(infuraEndPoint)
+	client, err := transactor.Dial(infuraEndPoint)
 	utility.CheckError("Error in connecting to Infura EndPoint:", err)
 
-	nonce, err := client.PendingNonceAt(context.Background(), crypto.PubkeyToAddress(*publicKey))
-	utility.CheckError("Error in fetching nonce:", err)
-	log.Infof("Nonce: %d", nonce)
+	// The node being unreachable at startup is not fatal, batches wait in the spool until it is back
+	nonce, err := client.PendingNonceAt(context.Background(), signer.Address())
+	if err != nil {
+		log.Warnf("Error in fetching nonce: %v", err)
+	} else {
+		log.Infof("Nonce: %d", nonce)
+	}
 
-	gasPrice, err := client.SuggestGasPrice(context.Background())
-	utility.CheckError("Error in fetching Gas Price:", err)
-	log.Infof("Gas Price: %d", gasPrice)
+	fees, err := newFeeStrategy(client)
+	utility.CheckError("Error in configuring fee strategy:", err)
+	if currentFees, err := fees.Fees(context.Background()); err != nil {
+		log.Warnf("Error in fetching fees: %v", err)
+	} else {
+		log.Infof("Fees: %s", currentFees)
+	}
 
 	balance, err := client.BalanceAt(context.Background(), common.HexToAddress(walletAddress), nil)
-	utility.CheckError("Error in fetching account balance:", err)
-	ethbalance := new(big.Float)
-	ethbalance.SetString(balance.String())
-	ethValue := new(big.Float).Quo(ethbalance, big.NewFloat(math.Pow10(18)))
-	log.Infof("ETH Balance: %f", ethValue)
+	if err != nil {
+		log.Warnf("Error in fetching account balance: %v", err)
+	} else {
+		ethbalance := new(big.Float)
+		ethbalance.SetString(balance.String())
+		ethValue := new(big.Float).Quo(ethbalance, big.NewFloat(math.Pow10(18)))
+		log.Infof("ETH Balance: %f", ethValue)
+	}
 
-	auth := bind.NewKeyedTransactor(privateKey)
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.Value = big.NewInt(0)     // in wei
-	auth.GasLimit = uint64(300000) // in units
-	auth.GasPrice = gasPrice
+	sources, err := tailer.LoadSources()
+	utility.CheckError("Error in reading log sources:", err)
+	state, err := checkpoint.Load(viper.GetString("CHECKPOINT_FILE"))
+	utility.CheckError("Error in loading tail checkpoints:", err)
+	logTailer := tailer.New(sources, viper.GetDuration("LOG_SCAN_INTERVAL"), state)
+	go func() {
+		err := logTailer.Run(context.Background())
+		utility.CheckError("Error in tailing log files:", err)
+	}()
 
-	var fileName = viper.Get("LOG_FILE_PATH").(string)
+	store, err := batch.NewStore(viper.GetString("BATCH_DIR"))
+	utility.CheckError("Error in opening batch directory:", err)
+	sequencer, err := batch.LoadSequencer(filepath.Join(store.Dir, "sequence.json"))
+	utility.CheckError("Error in loading batch sequences:", err)
 
-	t, err := tail.TailFile(fileName, tail.Config{Follow: true})
-	utility.CheckError("Error in parsing log file: ", err)
+	// Every tailed line goes through the on-disk spool and stays there until all sinks accepted it
+	lineSpool, err := spool.Open(viper.GetString("SPOOL_DIR"), viper.GetInt64("SPOOL_SEGMENT_SIZE"))
+	utility.CheckError("Error in opening spool:", err)
+	defer lineSpool.Close()
+	go spoolLines(logTailer.Lines(), lineSpool, state)
+	go reportBacklog(lineSpool, viper.GetDuration("SPOOL_REPORT_INTERVAL"))
 
-	for line := range t.Lines {
-		nonce, err := client.PendingNonceAt(context.Background(), crypto.PubkeyToAddress(*publicKey))
-		utility.CheckError("Error in fetching nonce:", err)
+	// Buffer lines into batches bounded by size and time window, lines of batches chained before
+	// but never confirmed are grouped back into those batches
+	spooled := make(chan model.Line)
+	batches := make(chan []model.Line)
+	go regroup(lineSpool.Records(), sequencer, spooled, batches)
+	batcher := &batch.Batcher{MaxLines: viper.GetInt("BATCH_SIZE"), Window: viper.GetDuration("BATCH_WINDOW")}
+	go batcher.Run(spooled, batches)
 
-		gasPrice, err := client.SuggestGasPrice(context.Background())
-		utility.CheckError("Error in fetching Gas Price:", err)
+	loggerAddress := common.HexToAddress(viper.Get("LOGGER_CONTRACT_ADDRESS").(string))
+	sinks, err := sink.New(viper.GetString("SINKS"), sink.Config{
+		Client:          client,
+		Signer:          signer,
+		ContractAddress: loggerAddress,
+		Fees:            fees,
+		GasMargin:       viper.GetUint64("GAS_LIMIT_MARGIN"),
+		Confirmations:   viper.GetUint64("CONFIRMATIONS"),
+		ReceiptInterval: viper.GetDuration("RECEIPT_POLL_INTERVAL"),
+		DropTimeout:     viper.GetDuration("DROP_TIMEOUT"),
+		StuckAfter:      viper.GetDuration("STUCK_TIMEOUT"),
+		InlinePayload:   viper.GetBool("ANCHOR_PAYLOAD"),
+		Store:           store,
+		Confirmed: func(b *batch.Batch, receipt *transactor.Receipt) {
+			err := store.SaveReceipt(b.Key(), receipt)
+			utility.CheckError("Error in saving receipt:", err)
+			// Lines of a failed anchor stay spooled and are anchored again on the next start
+			if receipt.Status == transactor.StatusConfirmed {
+				ackBatch(lineSpool, sequencer, b)
+			}
+		},
+		FilePath: viper.GetString("SINK_FILE_PATH"),
+		RepoPath: viper.GetString("REPO_PATH"),
+		ThreadID: viper.GetString("THREAD_ID"),
+		Backoff: utility.Backoff{
+			Min: viper.GetDuration("RETRY_MIN_DELAY"),
+			Max: viper.GetDuration("RETRY_MAX_DELAY"),
+		},
+	})
+	utility.CheckError("Error in configuring sinks:", err)
+	defer sinks.Close()
+	_, anchored := sinks["chain"]
 
-		balance, err := client.BalanceAt(context.Background(), common.HexToAddress(walletAddress), nil)
-		utility.CheckError("Error in fetching account balance:", err)
-		ethbalance := new(big.Float)
-		ethbalance.SetString(balance.String())
-		ethValue := new(big.Float).Quo(ethbalance, big.NewFloat(math.Pow10(18)))
-		log.Infof("Nonce: %d | Gas Price: %d | ETH Balance: %f", nonce, gasPrice, ethValue)
+	for batchLines := range batches {
+		b, err := batch.New(batchLines)
+		utility.CheckError("Error in building Merkle tree:", err)
+
+		// Every batch links to the previous batch of its source and the agent signs it so its origin can be checked offline
+		err = sequencer.Chain(b)
+		utility.CheckError("Error in chaining batch:", err)
+		err = b.Sign(signer)
+		utility.CheckError("Error in signing batch:", err)
 
-		auth := bind.NewKeyedTransactor(privateKey)
-		auth.Nonce = big.NewInt(int64(nonce))
-		auth.Value = big.NewInt(0)     // in wei
-		auth.GasLimit = uint64(300000) // in units
-		auth.GasPrice = gasPrice
+		// Encrypt the batch of log data
+		plainBatch, err := json.Marshal(b.Lines)
+		utility.CheckError("Error in encoding batch:", err)
+		compressedBatch, err := codec.Compress(plainBatch)
+		utility.CheckError("Error in compressing batch:", err)
+		// Pick up keys rotated through the key rotate command and rotate when the current key is due
+		err = ring.Reload()
+		utility.CheckError("Error in reloading keyring:", err)
+		if ring.Due(rotation) {
+			key, err := ring.Rotate()
+			utility.CheckError("Error in rotating encryption key:", err)
+			log.Infof("Rotated Encryption Key: %s", key.ID)
+		}
+		current := &ring.Current().PrivateKey.PublicKey
+		b.Payload, err = envelope.Seal(compressedBatch, codec.Algorithm(), append([]*ecies.PublicKey{current}, recipients...))
+		utility.CheckError("Error in encrypting batch:", err)
+		if codec.Algorithm() != compression.None {
+			message := fmt.Sprintf("Compressed batch with %s from %d to %d bytes", codec.Algorithm(), len(plainBatch), len(compressedBatch))
+			// Calldata gas only changes when the payload itself goes on-chain
+			if anchored && viper.GetBool("ANCHOR_PAYLOAD") {
+				var saved uint64
+				if plainGas, compressedGas := transactor.CalldataGas(plainBatch), transactor.CalldataGas(compressedBatch); plainGas > compressedGas {
+					saved = plainGas - compressedGas
+				}
+				message += fmt.Sprintf(" | Calldata gas saved: %d", saved)
+			}
+			log.Info(message)
+		}
 
-		loggerAddress := common.HexToAddress(viper.Get("LOGGER_CONTRACT_ADDRESS").(string))
-		instance, err := logger.NewLogger(loggerAddress, client)
-		utility.CheckError("Unable to load instance of the deployed contract:", err)
+		// Keep the encrypted batch and every line's inclusion proof locally
+		err = store.Save(b)
+		utility.CheckError("Error in saving batch:", err)
+		log.Infof("Batch %d of %d lines from %s | Merkle Root: %s | Pointer: %s", b.Sequence, len(b.Lines), b.Source, b.Root().Hex(), b.Pointer)
 
-		// Encrypt the log data
-		encryptedLogData, err := ecies.Encrypt(rand.Reader, &eciesPublicKey, []byte(line.Text), nil, nil)
-		if err != nil {
-			panic(err)
+		err = sinks.Submit(context.Background(), b)
+		utility.CheckError("Unable to submit batch:", err)
+		err = sinks.Flush(context.Background())
+		utility.CheckError("Unable to flush sinks:", err)
+
+		// Without the chain sink there is no confirmation to wait for
+		if !anchored {
+			ackBatch(lineSpool, sequencer, b)
 		}
-		log.Infof("Encrypted Log Data: %s", hex.EncodeToString(encryptedLogData))
+	}
+}
+
+// newSigner signs through the external signing daemon when one is configured and with the agent's own key otherwise
+func newSigner() (transactor.Signer, string, error) {
+	if endpoint := viper.GetString("SIGNER_ENDPOINT"); endpoint != "" {
+		address := viper.GetString("SIGNER_ADDRESS")
+		if !common.IsHexAddress(address) {
+			return nil, "", fmt.Errorf("SIGNER_ADDRESS %q is not an address", address)
+		}
+		signer, err := transactor.NewExternalSigner(endpoint, common.HexToAddress(address))
+		return signer, "external signer " + endpoint, err
+	}
+	privateKey, source, err := signingKey()
+	if err != nil {
+		return nil, "", err
+	}
+	return transactor.NewKeySigner(privateKey), source, nil
+}
+
+// signingKey loads the transaction signing key from the keystore, key file or mnemonic configured
+func signingKey() (*ecdsa.PrivateKey, string, error) {
+	return wallet.KeySource{
+		Keystore:           viper.GetString("KEYSTORE_FILE"),
+		PassphraseFile:     viper.GetString("KEYSTORE_PASSPHRASE_FILE"),
+		PassphraseEnv:      viper.GetString("KEYSTORE_PASSPHRASE_ENV"),
+		KeyFile:            viper.GetString("PRIVATE_KEY_FILE"),
+		Mnemonic:           viper.GetString("MNEMONIC"),
+		MnemonicPassphrase: viper.GetString("MNEMONIC_PASSPHRASE"),
+		Path:               viper.GetString("SIGNING_KEY_PATH"),
+	}.Load()
+}
 
-		tx, err := instance.DataLog(auth, hex.EncodeToString(encryptedLogData))
-		utility.CheckError("Unable to write into the contract method:", err)
+// deriveKey derives the key at the derivation path configured under pathKey from the mnemonic and its passphrase
+func deriveKey(pathKey string) (*ecdsa.PrivateKey, *ecdsa.PublicKey, *string, error) {
+	return wallet.DeriveKey(viper.GetString("MNEMONIC"), viper.GetString("MNEMONIC_PASSPHRASE"), viper.GetString(pathKey))
+}
 
-		// Decryption
-		decryptedLogData, err := eciesPrivateKey.Decrypt(encryptedLogData, nil, nil)
+// newFeeStrategy builds the fee strategy selected through config
+func newFeeStrategy(client *transactor.Node) (*transactor.FeeStrategy, error) {
+	gwei := map[string]*big.Int{}
+	for _, key := range []string{"FEE_FIXED_TIP", "FEE_FIXED_GAS_PRICE", "FEE_MAX_FEE", "FEE_MAX_TIP"} {
+		value, err := transactor.ParseGwei(viper.GetString(key))
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("%s: %v", key, err)
 		}
-		log.Infof("TX Hash: %s --> Decrypted DataLog: %s", tx.Hash().Hex(), string(decryptedLogData))
+		gwei[key] = value
+	}
+	pricer, err := transactor.NewPricer(viper.GetString("FEE_STRATEGY"), client, transactor.PricerConfig{
+		FixedTip:   gwei["FEE_FIXED_TIP"],
+		FixedPrice: gwei["FEE_FIXED_GAS_PRICE"],
+		Blocks:     viper.GetInt("FEE_PERCENTILE_BLOCKS"),
+		Percentile: viper.GetInt("FEE_PERCENTILE"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	strategy := transactor.NewFeeStrategy(client, pricer)
+	strategy.MaxFeeCap = gwei["FEE_MAX_FEE"]
+	strategy.MaxTipCap = gwei["FEE_MAX_TIP"]
+	return strategy, nil
+}
+
+// spoolLines appends tailed lines to the spool, syncing whenever the tailer has nothing more to
+// hand over. Tail checkpoints advance once lines are synced, the spool then owns their delivery
+func spoolLines(lines <-chan model.Line, lineSpool *spool.Spool, state *checkpoint.State) {
+	for line := range lines {
+		for more := true; more; {
+			_, err := lineSpool.Append(line)
+			utility.CheckError("Error in appending to spool:", err)
+			state.Set(line.File, checkpoint.Position{Offset: line.Offset, Inode: line.Inode})
+			select {
+			case line, more = <-lines:
+			default:
+				more = false
+			}
+		}
+		err := lineSpool.Sync()
+		utility.CheckError("Error in syncing spool:", err)
+		err = state.Save()
+		utility.CheckError("Error in saving tail checkpoints:", err)
+	}
+}
+
+// regroup passes spooled lines on to the batcher, except lines of batches chained before a
+// restart, which are collected and sent back as the same batch to be anchored again
+func regroup(records <-chan spool.Record, sequencer *batch.Sequencer, spooled chan<- model.Line, batches chan<- []model.Line) {
+	defer close(spooled)
+	groups := map[uint64][]model.Line{}
+	for record := range records {
+		record.Line.Position = record.Seq
+		positions, ok := sequencer.Reserved(record.Seq)
+		if !ok {
+			spooled <- record.Line
+			continue
+		}
+		first := positions[0]
+		groups[first] = append(groups[first], record.Line)
+		if len(groups[first]) == len(positions) {
+			batches <- groups[first]
+			delete(groups, first)
+		}
+	}
+}
+
+// ackBatch lets the lines of a delivered batch leave the spool and releases its sequence reservation
+func ackBatch(lineSpool *spool.Spool, sequencer *batch.Sequencer, b *batch.Batch) {
+	positions := make([]uint64, len(b.Lines))
+	for i, line := range b.Lines {
+		positions[i] = line.Position
+	}
+	err := lineSpool.Ack(positions...)
+	utility.CheckError("Error in acknowledging spooled lines:", err)
+	err = sequencer.Confirm(b)
+	utility.CheckError("Error in confirming batch sequence:", err)
+	count, size := lineSpool.Backlog()
+	log.Infof("Spool Backlog: %d lines | %d bytes", count, size)
+}
+
+// reportBacklog logs the size of the spool at every interval
+func reportBacklog(lineSpool *spool.Spool, interval time.Duration) {
+	for range time.Tick(interval) {
+		count, size := lineSpool.Backlog()
+		log.Infof("Spool Backlog: %d lines | %d bytes", count, size)
 	}
 }
@@ -0,0 +1,112 @@
+package merkle
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Domain separation prefixes so that a leaf can never be passed off as an inner node
+var (
+	leafPrefix = []byte{0x00}
+	nodePrefix = []byte{0x01}
+)
+
+// ErrEmpty is returned when a tree is built without any leaves
+var ErrEmpty = errors.New("merkle: no leaves")
+
+// Step is one sibling on the path from a leaf to the root
+type Step struct {
+	Hash common.Hash `json:"hash"`
+	Left bool        `json:"left"` // Sibling sits on the left of the running hash
+}
+
+// Proof is the inclusion proof of a single leaf
+type Proof []Step
+
+// Tree Keccak256 binary Merkle tree, an odd node is carried up to the next level unchanged
+type Tree struct {
+	levels [][]common.Hash
+}
+
+// LeafHash hashes raw leaf data
+func LeafHash(data []byte) common.Hash {
+	return crypto.Keccak256Hash(leafPrefix, data)
+}
+
+// NodeHash hashes two child nodes
+func NodeHash(left, right common.Hash) common.Hash {
+	return crypto.Keccak256Hash(nodePrefix, left.Bytes(), right.Bytes())
+}
+
+// New builds a tree from raw leaf data
+func New(leaves [][]byte) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, ErrEmpty
+	}
+	level := make([]common.Hash, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = LeafHash(leaf)
+	}
+	tree := &Tree{levels: [][]common.Hash{level}}
+	for len(level) > 1 {
+		next := make([]common.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, NodeHash(level[i], level[i+1]))
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+	return tree, nil
+}
+
+// Root of the tree
+func (tree *Tree) Root() common.Hash {
+	return tree.levels[len(tree.levels)-1][0]
+}
+
+// Len number of leaves in the tree
+func (tree *Tree) Len() int {
+	return len(tree.levels[0])
+}
+
+// Leaf hash of the leaf at index
+func (tree *Tree) Leaf(index int) common.Hash {
+	return tree.levels[0][index]
+}
+
+// Proof computes the inclusion proof of the leaf at index
+func (tree *Tree) Proof(index int) Proof {
+	proof := Proof{}
+	for _, level := range tree.levels[:len(tree.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, Step{Hash: level[sibling], Left: sibling < index})
+		}
+		index /= 2
+	}
+	return proof
+}
+
+// Verify checks that the raw leaf data is included under root
+func Verify(root common.Hash, leaf []byte, proof Proof) bool {
+	return VerifyHash(root, LeafHash(leaf), proof)
+}
+
+// VerifyHash checks that an already hashed leaf is included under root
+func VerifyHash(root common.Hash, leaf common.Hash, proof Proof) bool {
+	hash := leaf
+	for _, step := range proof {
+		if step.Left {
+			hash = NodeHash(step.Hash, hash)
+		} else {
+			hash = NodeHash(hash, step.Hash)
+		}
+	}
+	return hash == root
+}
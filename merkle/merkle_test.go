@@ -0,0 +1,35 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestProofs Every leaf verifies against the root for odd and even tree sizes
+func TestProofs(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = []byte(fmt.Sprintf("line %d", i))
+		}
+		tree, err := New(leaves)
+		if err != nil {
+			t.Fatalf("Failed to build tree of size %d: %v", size, err)
+		}
+		for i, leaf := range leaves {
+			if !Verify(tree.Root(), leaf, tree.Proof(i)) {
+				t.Errorf("Proof for leaf %d of %d did not verify", i, size)
+			}
+			if Verify(tree.Root(), []byte("tampered"), tree.Proof(i)) {
+				t.Errorf("Tampered leaf %d of %d verified", i, size)
+			}
+		}
+	}
+}
+
+// TestEmpty Building a tree without leaves fails
+func TestEmpty(t *testing.T) {
+	if _, err := New(nil); err != ErrEmpty {
+		t.Errorf("Expected ErrEmpty, received %v", err)
+	}
+}
@@ -13,3 +13,16 @@ type Log struct {
 	Type      string          `json:"type"`
 	Data      string          `json:"data"`
 }
+
+// Line Struct for a single line read from a monitored log file
+type Line struct {
+	Source string    `json:"source"`         // Label of the configured log source
+	Kind   string    `json:"kind,omitempty"` // Empty for log lines, set for internal events
+	File   string    `json:"file"`           // Path of the file the line was read from
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+	Offset int64     `json:"offset"` // Byte offset right after the line
+	Inode  uint64    `json:"inode"`  // Inode of the file when the line was read
+
+	Position uint64 `json:"-"` // Sequence number of the line in the local spool
+}
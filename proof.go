@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/audit"
+	"github.com/TheLazarusNetwork/Monitor/utility"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// proof dispatches the proof bundle commands, verify runs offline and needs no config
+//
+//	monitor proof export -tx 0x.. (-line 3 | -match "GET /admin") [-log 0] [-out bundle.json] [key flags]
+//	monitor proof verify [-contract 0x..] [-sender 0x..] bundle.json
+func proof(args []string) {
+	if len(args) == 0 {
+		log.Fatal("proof needs a command: export or verify")
+	}
+	switch args[0] {
+	case "export":
+		exportProof(args[1:])
+	case "verify":
+		verifyProof(args[1:])
+	default:
+		log.Fatalf("Unknown proof command: %s (expected export or verify)", args[0])
+	}
+}
+
+// exportProof writes the proof bundle of one line of the batch anchored by a transaction
+func exportProof(args []string) {
+	flags := flag.NewFlagSet("proof export", flag.ExitOnError)
+	tx := flags.String("tx", "", "hash of the transaction anchoring the batch")
+	n := flags.Int("log", 0, "which Log event of the transaction, when it logged several")
+	line := flags.Int("line", -1, "position of the line in the batch")
+	match := flags.String("match", "", "export the first line of the batch containing this text instead")
+	out := flags.String("out", "", "file the bundle is written to instead of stdout")
+	keys := keyFlags(flags)
+	flags.Parse(args)
+	if !strings.HasPrefix(*tx, "0x") || len(*tx) != 66 {
+		log.Fatal("proof export needs the -tx hash of the anchoring transaction")
+	}
+	if (*line < 0) == (*match == "") {
+		log.Fatal("proof export needs either -line or -match")
+	}
+
+	decoder, err := keys.decoder()
+	utility.CheckError("Error in loading decryption keys:", err)
+	client, err := ethclient.Dial(viper.GetString("INFURA_ENDPOINT"))
+	utility.CheckError("Error in connecting to Infura EndPoint:", err)
+	defer client.Close()
+	contract := common.HexToAddress(viper.GetString("LOGGER_CONTRACT_ADDRESS"))
+	evidence, err := audit.Collect(context.Background(), client, contract, common.HexToHash(*tx), *n, decoder)
+	utility.CheckError("Error in collecting evidence:", err)
+
+	if *match != "" {
+		for i, candidate := range evidence.Entry.Lines {
+			if strings.Contains(candidate.Text, *match) {
+				*line = i
+				break
+			}
+		}
+		if *line < 0 {
+			log.Fatalf("No line of the batch contains %q", *match)
+		}
+	}
+	bundle, err := evidence.Bundle(*line)
+	utility.CheckError("Error in building bundle:", err)
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	utility.CheckError("Error in encoding bundle:", err)
+	if *out == "" {
+		fmt.Println(string(data))
+		return
+	}
+	err = ioutil.WriteFile(*out, append(data, '\n'), 0600)
+	utility.CheckError("Error in writing bundle:", err)
+	log.Infof("Bundle of line %d in block %d written to %s", *line, evidence.Event.BlockNumber, *out)
+}
+
+// verifyProof checks a proof bundle without any node and prints what it proves, it exits with status 1 when the bundle fails
+func verifyProof(args []string) {
+	flags := flag.NewFlagSet("proof verify", flag.ExitOnError)
+	contract := flags.String("contract", viper.GetString("LOGGER_CONTRACT_ADDRESS"), "expected Logger contract, empty to accept any")
+	sender := flags.String("sender", "", "expected agent address")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("proof verify needs the bundle file")
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	utility.CheckError("Error in reading bundle:", err)
+	bundle := &audit.Bundle{}
+	err = json.Unmarshal(data, bundle)
+	utility.CheckError("Error in decoding bundle:", err)
+	var expected common.Address
+	if *contract != "" {
+		expected = common.HexToAddress(*contract)
+	}
+	proven, err := audit.VerifyBundle(bundle, expected)
+	utility.CheckError("Bundle does not verify:", err)
+	if *sender != "" && proven.Sender != common.HexToAddress(*sender) {
+		log.Fatalf("Bundle does not verify: logged by %s instead of %s", proven.Sender.Hex(), *sender)
+	}
+
+	fmt.Printf("Line:      %s\n", proven.Line.Text)
+	if proven.Signed {
+		fmt.Printf("Batch:     %s #%d, signed by the agent\n", proven.Source, proven.Sequence)
+	}
+	fmt.Printf("Agent:     %s\n", proven.Sender.Hex())
+	fmt.Printf("Contract:  %s\n", proven.Contract.Hex())
+	fmt.Printf("Block:     %d %s\n", proven.Block, proven.BlockHash.Hex())
+	fmt.Printf("Timestamp: %s\n", proven.Time.Format(time.RFC3339))
+	fmt.Println("Compare the block hash with a trusted source of the chain to complete the proof")
+}
@@ -0,0 +1,210 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/transactor"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Chain anchors the Merkle root, pointer and optionally the encrypted payload of every batch through the Logger contract
+type Chain struct {
+	client    *transactor.Node
+	signer    transactor.Signer
+	chainID   *big.Int
+	nonces    *transactor.NonceManager
+	fees      *transactor.FeeStrategy
+	gasMargin uint64
+	contract  common.Address
+	abi       abi.ABI
+	instance  *logger.Logger
+	refreshed time.Time
+	tracker   *transactor.Tracker
+	confirmed func(*batch.Batch, *transactor.Receipt)
+	store     *batch.Store
+	inline    bool
+	stop      context.CancelFunc
+	mutex     sync.Mutex // Serialises sending between Submit and the tracker's resubmissions
+}
+
+// nonceRefreshInterval how often the local nonces are compared with the node
+const nonceRefreshInterval = time.Minute
+
+// NewChain binds to the deployed Logger contract and starts tracking the receipts of its transactions
+func NewChain(client *transactor.Node, signer transactor.Signer, contractAddress common.Address, config Config) (*Chain, error) {
+	parsed, err := abi.JSON(strings.NewReader(logger.LoggerABI))
+	if err != nil {
+		return nil, err
+	}
+	instance, err := logger.NewLogger(contractAddress, client)
+	if err != nil {
+		return nil, err
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	chain := &Chain{
+		client:    client,
+		signer:    signer,
+		nonces:    transactor.NewNonceManager(client, signer.Address()),
+		fees:      config.Fees,
+		gasMargin: config.GasMargin,
+		contract:  contractAddress,
+		abi:       parsed,
+		instance:  instance,
+		refreshed: time.Now(),
+		tracker:   transactor.NewTracker(client, config.Confirmations, config.DropTimeout),
+		confirmed: config.Confirmed,
+		store:     config.Store,
+		inline:    config.InlinePayload,
+		stop:      stop,
+	}
+	if config.StuckAfter > 0 {
+		chain.tracker.ReplaceStuck(transactor.NewReplacer(client, config.Fees, chain.nonces.Address(), chain.sign), config.StuckAfter)
+	}
+	go chain.tracker.Run(ctx, config.ReceiptInterval)
+	return chain, nil
+}
+
+// Submit sends one DataLog transaction carrying the batch anchor and tracks it until it is confirmed,
+// a batch still pending from before a restart is tracked again instead of being sent anew
+func (chain *Chain) Submit(ctx context.Context, b *batch.Batch) error {
+	record := b.Anchor()
+	if !chain.inline {
+		record.Payload = nil
+	}
+	anchor, err := record.Encode()
+	if err != nil {
+		return err
+	}
+	resubmit := func(ctx context.Context, previous *types.Transaction) (*types.Transaction, error) {
+		// Rebroadcast the signed transaction while its nonce is free, otherwise send the anchor anew
+		err := chain.client.SendTransaction(ctx, previous)
+		if err == nil || strings.Contains(err.Error(), "already known") {
+			return previous, nil
+		}
+		if !transactor.IsNonceTooLow(err) {
+			return nil, err
+		}
+		return chain.send(ctx, anchor)
+	}
+	done := func(receipt *transactor.Receipt) {
+		log.Infof("TX Hash: %s --> %s in block %d at index %d", receipt.TxHash.Hex(), receipt.Status, receipt.BlockNumber, receipt.TxIndex)
+		if chain.confirmed != nil {
+			chain.confirmed(b, receipt)
+		}
+	}
+	var pending func(*transactor.Receipt)
+	if chain.store != nil {
+		pending = func(receipt *transactor.Receipt) {
+			if err := chain.store.SaveReceipt(b.Key(), receipt); err != nil {
+				log.Warnf("Error in recording pending anchor %s: %v", receipt.TxHash.Hex(), err)
+			}
+		}
+		if previous, err := chain.store.LoadReceipt(b.Key()); err == nil && previous.Status == transactor.StatusPending {
+			log.Infof("TX Hash: %s --> Merkle Root: %s | Resumed after restart", previous.TxHash.Hex(), b.Root().Hex())
+			return chain.tracker.Resume(previous, resubmit, done, pending)
+		}
+	}
+
+	tx, err := chain.send(ctx, anchor)
+	if err != nil {
+		return err
+	}
+	log.Infof("TX Hash: %s --> Merkle Root: %s", tx.Hash().Hex(), b.Root().Hex())
+	chain.tracker.TrackRecorded(tx, resubmit, done, pending)
+	return nil
+}
+
+// send prices, signs and broadcasts a DataLog transaction without waiting for it to be mined
+func (chain *Chain) send(ctx context.Context, anchor string) (*types.Transaction, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	if chain.chainID == nil {
+		chainID, err := chain.client.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		chain.chainID = chainID
+	}
+	if time.Since(chain.refreshed) > nonceRefreshInterval {
+		if err := chain.nonces.Refresh(ctx); err != nil {
+			return nil, err
+		}
+		chain.refreshed = time.Now()
+	}
+
+	fees, err := chain.fees.Fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Gas depends on the size of the anchor, estimate it for the actual calldata
+	data, err := chain.abi.Pack("dataLog", anchor)
+	if err != nil {
+		return nil, err
+	}
+	gasLimit, err := transactor.EstimateGas(ctx, chain.client, ethereum.CallMsg{
+		From:      chain.nonces.Address(),
+		To:        &chain.contract,
+		GasPrice:  fees.GasPrice,
+		GasFeeCap: fees.GasFeeCap,
+		GasTipCap: fees.GasTipCap,
+		Data:      data,
+	}, chain.gasMargin)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := chain.nonces.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Nonce: %d | %s | Gas Limit: %d | Pending Transactions: %d", nonce, fees, gasLimit, chain.tracker.Pending())
+
+	auth := transactor.Transactor(chain.signer, chain.chainID)
+	auth.Nonce = new(big.Int).SetUint64(nonce)
+	auth.Value = big.NewInt(0) // in wei
+	auth.GasLimit = gasLimit   // in units
+	fees.Apply(auth)
+	auth.Context = ctx
+
+	tx, err := chain.instance.DataLog(auth, anchor)
+	if err != nil {
+		chain.nonces.Fail(nonce, err)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// sign signs a transaction as the agent for the connected chain
+func (chain *Chain) sign(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	chain.mutex.Lock()
+	chainID := chain.chainID
+	chain.mutex.Unlock()
+	if chainID == nil {
+		return nil, fmt.Errorf("chain id not known yet")
+	}
+	return transactor.Transactor(chain.signer, chainID).Signer(from, tx)
+}
+
+// Flush is a no-op, every batch is sent as soon as it is submitted
+func (chain *Chain) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close stops tracking receipts and disconnects from the Ethereum node
+func (chain *Chain) Close() error {
+	chain.stop()
+	chain.client.Close()
+	return nil
+}
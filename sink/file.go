@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Record the JSON line written for every batch by the file and stdout sinks
+type Record struct {
+	Time      time.Time     `json:"time"`
+	Source    string        `json:"source"`
+	Sequence  uint64        `json:"sequence"`
+	Root      common.Hash   `json:"root"`
+	Count     int           `json:"count"`
+	Signature hexutil.Bytes `json:"signature"`
+	Pointer   string        `json:"ptr"`
+	Payload   []byte        `json:"payload"`
+}
+
+// Writer writes one JSON record per batch to an io.Writer
+type Writer struct {
+	buffer *bufio.Writer
+	closer io.Closer
+}
+
+// NewFile appends batch records to the file at path
+func NewFile(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{buffer: bufio.NewWriter(file), closer: file}, nil
+}
+
+// NewStdout writes batch records to the standard output
+func NewStdout() *Writer {
+	return &Writer{buffer: bufio.NewWriter(os.Stdout)}
+}
+
+// Submit buffers the record of the batch
+func (writer *Writer) Submit(ctx context.Context, b *batch.Batch) error {
+	record := Record{
+		Time:      b.Time,
+		Source:    b.Source,
+		Sequence:  b.Sequence,
+		Root:      b.Root(),
+		Count:     len(b.Lines),
+		Signature: b.Signature,
+		Pointer:   b.Pointer,
+		Payload:   b.Payload,
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = writer.buffer.Write(append(data, '\n'))
+	return err
+}
+
+// Flush writes out buffered records
+func (writer *Writer) Flush(ctx context.Context) error {
+	return writer.buffer.Flush()
+}
+
+// Close flushes and closes the underlying file
+func (writer *Writer) Close() error {
+	err := writer.buffer.Flush()
+	if writer.closer != nil {
+		if closeErr := writer.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/utility"
+)
+
+// Retry wraps a sink so that failed submissions are retried with exponential backoff
+// instead of being lost, other sinks of a Multi are not resubmitted to
+type Retry struct {
+	Sink
+	Name    string
+	Backoff utility.Backoff
+}
+
+// Submit retries the wrapped sink until it accepts the batch or ctx is done
+func (retry *Retry) Submit(ctx context.Context, b *batch.Batch) error {
+	return retry.Backoff.Retry(ctx, "Submitting to "+retry.Name+" sink", func() error {
+		return retry.Sink.Submit(ctx, b)
+	})
+}
@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/transactor"
+	"github.com/TheLazarusNetwork/Monitor/utility"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Sink a destination the pipeline writes encrypted batches to
+type Sink interface {
+	// Submit hands a batch over to the sink
+	Submit(ctx context.Context, b *batch.Batch) error
+	// Flush pushes out anything the sink still buffers
+	Flush(ctx context.Context) error
+	// Close flushes and releases the resources held by the sink
+	Close() error
+}
+
+// Config everything needed to build the sinks selected through config
+type Config struct {
+	Client          *transactor.Node
+	Signer          transactor.Signer
+	ContractAddress common.Address
+	Fees            *transactor.FeeStrategy
+	GasMargin       uint64        // Percent added to gas estimates
+	Confirmations   uint64        // Blocks on top of an anchor before it counts as confirmed
+	ReceiptInterval time.Duration // How often receipts of pending anchors are checked
+	DropTimeout     time.Duration // How long a transaction may be unknown to the node before it is resent
+	StuckAfter      time.Duration // How long a transaction may be pending before it is sped up, 0 never
+	InlinePayload   bool          // Carry the encrypted batch in the anchor itself rather than only its pointer
+	FilePath        string
+	RepoPath        string          // Directory the Thread DB of the textile sink is kept in
+	ThreadID        string          // Thread of the textile sink, the one saved in RepoPath when empty
+	Backoff         utility.Backoff // Retry delays of failed submissions, retries are disabled when zero
+
+	// Store records pending anchors so they are tracked again after a restart
+	Store *batch.Store
+	// Confirmed is called once the anchor of a batch is confirmed or failed for good
+	Confirmed func(*batch.Batch, *transactor.Receipt)
+}
+
+// New builds the sinks named in a comma separated list such as "chain,textile"
+func New(names string, config Config) (Multi, error) {
+	multi := Multi{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := multi[name]; ok {
+			return nil, fmt.Errorf("sink %q configured twice", name)
+		}
+		var s Sink
+		var err error
+		switch name {
+		case "chain":
+			s, err = NewChain(config.Client, config.Signer, config.ContractAddress, config)
+		case "textile":
+			s, err = NewTextile(config.RepoPath, config.ThreadID)
+		case "file":
+			s, err = NewFile(config.FilePath)
+		case "stdout":
+			s = NewStdout()
+		default:
+			err = fmt.Errorf("unknown sink %q", name)
+		}
+		if err != nil {
+			multi.Close()
+			return nil, err
+		}
+		if config.Backoff.Min > 0 {
+			s = &Retry{Sink: s, Name: name, Backoff: config.Backoff}
+		}
+		multi[name] = s
+	}
+	if len(multi) == 0 {
+		return nil, fmt.Errorf("no sink configured")
+	}
+	return multi, nil
+}
+
+// Multi fans every call out to a set of named sinks
+type Multi map[string]Sink
+
+// Submit hands the batch to every sink, a failing sink does not stop the others
+func (multi Multi) Submit(ctx context.Context, b *batch.Batch) error {
+	return multi.each(func(s Sink) error { return s.Submit(ctx, b) })
+}
+
+// Flush flushes every sink
+func (multi Multi) Flush(ctx context.Context) error {
+	return multi.each(func(s Sink) error { return s.Flush(ctx) })
+}
+
+// Close closes every sink
+func (multi Multi) Close() error {
+	return multi.each(func(s Sink) error { return s.Close() })
+}
+
+func (multi Multi) each(call func(Sink) error) error {
+	var failed []string
+	for name, s := range multi {
+		if err := call(s); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("sink error: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
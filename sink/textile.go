@@ -0,0 +1,57 @@
+package sink
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/datastore"
+	"github.com/TheLazarusNetwork/Monitor/model"
+
+	"github.com/textileio/go-threads/db"
+)
+
+// Textile stores every encrypted batch in the Logs collection of a Thread DB
+type Textile struct {
+	db         *db.DB
+	collection *db.Collection
+	closer     func()
+}
+
+// NewTextile opens the Thread DB kept in repo and its Logs collection, thread overrides the thread saved in repo
+func NewTextile(repo string, thread string) (*Textile, error) {
+	d, closer, err := datastore.CreateDB(repo, thread)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := datastore.CreateCollection(d)
+	if err != nil {
+		closer()
+		return nil, err
+	}
+	return &Textile{db: d, collection: collection, closer: closer}, nil
+}
+
+// Submit stores the encrypted batch as a single log instance
+func (textile *Textile) Submit(ctx context.Context, b *batch.Batch) error {
+	entry := &model.Log{
+		Timestamp: time.Now().UTC(),
+		Type:      b.Source,
+		Data:      hex.EncodeToString(b.Payload),
+	}
+	_, err := datastore.CreateLog(textile.collection, entry)
+	return err
+}
+
+// Flush is a no-op, every instance is written in its own transaction
+func (textile *Textile) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close shuts the Thread DB and its network down, the repo is kept for the next start
+func (textile *Textile) Close() error {
+	err := textile.db.Close()
+	textile.closer()
+	return err
+}
@@ -0,0 +1,62 @@
+package spool
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// cursor the acknowledged part of the spool
+type cursor struct {
+	path    string
+	Acked   uint64   `json:"acked"`   // Every record up to this one is acknowledged
+	Pending []uint64 `json:"pending"` // Records acknowledged out of order past Acked
+}
+
+func loadCursor(path string) (*cursor, error) {
+	c := &cursor{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, c)
+	return c, err
+}
+
+// ack records seq and moves Acked past every contiguous acknowledged record
+func (c *cursor) ack(seq uint64) {
+	if c.isAcked(seq) {
+		return
+	}
+	c.Pending = append(c.Pending, seq)
+	sort.Slice(c.Pending, func(i, j int) bool { return c.Pending[i] < c.Pending[j] })
+	for len(c.Pending) > 0 && c.Pending[0] == c.Acked+1 {
+		c.Acked++
+		c.Pending = c.Pending[1:]
+	}
+}
+
+func (c *cursor) isAcked(seq uint64) bool {
+	if seq <= c.Acked {
+		return true
+	}
+	i := sort.Search(len(c.Pending), func(i int) bool { return c.Pending[i] >= seq })
+	return i < len(c.Pending) && c.Pending[i] == seq
+}
+
+// save writes the cursor atomically
+func (c *cursor) save() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
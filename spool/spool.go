@@ -0,0 +1,337 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/TheLazarusNetwork/Monitor/model"
+)
+
+// segmentExt extension of the segment files holding spooled records
+const segmentExt = ".seg"
+
+// Record a spooled line and its position in the spool
+type Record struct {
+	Seq  uint64     `json:"seq"`
+	Line model.Line `json:"line"`
+}
+
+// Spool write-ahead queue of log lines on local disk, lines stay spooled until acknowledged
+type Spool struct {
+	dir         string
+	segmentSize int64
+
+	mutex    sync.Mutex
+	changed  chan struct{} // Closed and replaced whenever new records become readable
+	segments []uint64      // Ids of the segment files, oldest first
+	writer   *bufio.Writer
+	file     *os.File
+	size     int64 // Size of the segment being written
+	lastSeq  uint64
+	cursor   *cursor
+	closed   bool
+
+	records chan Record
+}
+
+// Open opens or creates the spool in dir, segment files are rolled over past segmentSize bytes
+func Open(dir string, segmentSize int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	cursor, err := loadCursor(filepath.Join(dir, "cursor"))
+	if err != nil {
+		return nil, err
+	}
+	spool := &Spool{
+		dir:         dir,
+		segmentSize: segmentSize,
+		changed:     make(chan struct{}),
+		cursor:      cursor,
+		lastSeq:     cursor.Acked,
+		records:     make(chan Record),
+	}
+	if err := spool.recover(); err != nil {
+		return nil, err
+	}
+	go spool.read()
+	return spool, nil
+}
+
+// recover lists the segments, drops a half written record and finds the last sequence number
+func (spool *Spool) recover() error {
+	names, err := filepath.Glob(filepath.Join(spool.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		var id uint64
+		if _, err := fmt.Sscanf(filepath.Base(name), "%d"+segmentExt, &id); err == nil {
+			spool.segments = append(spool.segments, id)
+		}
+	}
+	sort.Slice(spool.segments, func(i, j int) bool { return spool.segments[i] < spool.segments[j] })
+	if len(spool.segments) == 0 {
+		return spool.roll()
+	}
+
+	last := spool.segmentPath(spool.segments[len(spool.segments)-1])
+	data, err := ioutil.ReadFile(last)
+	if err != nil {
+		return err
+	}
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if end < len(data) {
+		if err := os.Truncate(last, int64(end)); err != nil {
+			return err
+		}
+	}
+	if end > 0 {
+		start := bytes.LastIndexByte(data[:end-1], '\n') + 1
+		record := Record{}
+		if err := json.Unmarshal(data[start:end], &record); err != nil {
+			return fmt.Errorf("spool segment %s: %v", last, err)
+		}
+		if record.Seq > spool.lastSeq {
+			spool.lastSeq = record.Seq
+		}
+	}
+	file, err := os.OpenFile(last, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	spool.file, spool.writer, spool.size = file, bufio.NewWriter(file), int64(end)
+	return nil
+}
+
+// roll starts a new segment file
+func (spool *Spool) roll() error {
+	id := uint64(1)
+	if len(spool.segments) > 0 {
+		id = spool.segments[len(spool.segments)-1] + 1
+	}
+	file, err := os.OpenFile(spool.segmentPath(id), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if spool.file != nil {
+		if err := spool.sync(); err != nil {
+			return err
+		}
+		spool.file.Close()
+	}
+	spool.segments = append(spool.segments, id)
+	spool.file, spool.writer, spool.size = file, bufio.NewWriter(file), 0
+	return nil
+}
+
+func (spool *Spool) segmentPath(id uint64) string {
+	return filepath.Join(spool.dir, fmt.Sprintf("%012d%s", id, segmentExt))
+}
+
+// Append adds a line to the spool, it is durable once Sync returns
+func (spool *Spool) Append(line model.Line) (uint64, error) {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	if spool.size >= spool.segmentSize {
+		if err := spool.roll(); err != nil {
+			return 0, err
+		}
+	}
+	record := Record{Seq: spool.lastSeq + 1, Line: line}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return 0, err
+	}
+	n, err := spool.writer.Write(append(data, '\n'))
+	spool.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	spool.lastSeq = record.Seq
+	return record.Seq, nil
+}
+
+// Sync writes the appended lines to disk and hands them to the reader
+func (spool *Spool) Sync() error {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	return spool.sync()
+}
+
+func (spool *Spool) sync() error {
+	if err := spool.writer.Flush(); err != nil {
+		return err
+	}
+	if err := spool.file.Sync(); err != nil {
+		return err
+	}
+	close(spool.changed)
+	spool.changed = make(chan struct{})
+	return nil
+}
+
+// Records the spooled lines not acknowledged yet, in order, including those left over from a previous run
+func (spool *Spool) Records() <-chan Record {
+	return spool.records
+}
+
+// Ack marks records as submitted, segments holding only acknowledged records are removed
+func (spool *Spool) Ack(seqs ...uint64) error {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	for _, seq := range seqs {
+		spool.cursor.ack(seq)
+	}
+	if err := spool.cursor.save(); err != nil {
+		return err
+	}
+	return spool.compact()
+}
+
+// compact removes fully acknowledged segments, the segment being written is always kept
+func (spool *Spool) compact() error {
+	for len(spool.segments) > 1 {
+		next := spool.segmentPath(spool.segments[1])
+		first, err := firstSeq(next)
+		if err != nil || first == 0 || first-1 > spool.cursor.Acked {
+			return err
+		}
+		if err := os.Remove(spool.segmentPath(spool.segments[0])); err != nil {
+			return err
+		}
+		spool.segments = spool.segments[1:]
+	}
+	return nil
+}
+
+// Backlog number of lines and bytes waiting in the spool
+func (spool *Spool) Backlog() (uint64, int64) {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	var size int64
+	for _, id := range spool.segments {
+		if info, err := os.Stat(spool.segmentPath(id)); err == nil {
+			size += info.Size()
+		}
+	}
+	return spool.lastSeq - spool.cursor.Acked - uint64(len(spool.cursor.Pending)), size
+}
+
+// Close syncs and closes the segment being written
+func (spool *Spool) Close() error {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	if spool.closed {
+		return nil
+	}
+	spool.closed = true
+	err := spool.sync()
+	if closeErr := spool.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// read delivers the unacknowledged records of every segment, waiting for syncs at the end of the spool
+func (spool *Spool) read() {
+	var id uint64
+	var reader *bufio.Reader
+	var file *os.File
+	var partial []byte
+	for {
+		spool.mutex.Lock()
+		changed, segments, closed := spool.changed, spool.segments, spool.closed
+		spool.mutex.Unlock()
+		if file == nil {
+			next := nextSegment(segments, id)
+			if next == 0 {
+				if closed {
+					close(spool.records)
+					return
+				}
+				<-changed
+				continue
+			}
+			var err error
+			if file, err = os.Open(spool.segmentPath(next)); err != nil {
+				<-changed
+				continue
+			}
+			id, reader = next, bufio.NewReader(file)
+		}
+
+		data, err := reader.ReadBytes('\n')
+		partial = append(partial, data...)
+		if err == io.EOF {
+			if id != segments[len(segments)-1] {
+				file.Close()
+				file, partial = nil, nil
+				continue
+			}
+			if closed {
+				file.Close()
+				close(spool.records)
+				return
+			}
+			<-changed
+			continue
+		}
+		if err != nil {
+			file.Close()
+			file, partial = nil, nil
+			continue
+		}
+
+		record := Record{}
+		err = json.Unmarshal(partial, &record)
+		partial = nil
+		if err != nil || spool.isAcked(record.Seq) {
+			continue
+		}
+		spool.records <- record
+	}
+}
+
+func (spool *Spool) isAcked(seq uint64) bool {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	return spool.cursor.isAcked(seq)
+}
+
+// nextSegment the first segment after id
+func nextSegment(segments []uint64, id uint64) uint64 {
+	for _, segment := range segments {
+		if segment > id {
+			return segment
+		}
+	}
+	return 0
+}
+
+// firstSeq sequence number of the first record in a segment, 0 for an empty segment
+func firstSeq(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	data, err := bufio.NewReader(file).ReadBytes('\n')
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	record := Record{}
+	err = json.Unmarshal(data, &record)
+	return record.Seq, err
+}
@@ -0,0 +1,78 @@
+package spool
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/model"
+)
+
+func next(t *testing.T, spool *Spool) Record {
+	select {
+	case record := <-spool.Records():
+		return record
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a spooled record")
+	}
+	return Record{}
+}
+
+// TestSpoolRestart Unacknowledged lines survive a restart and are delivered again
+func TestSpoolRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	spool, err := Open(dir, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"one", "two", "three", "four"} {
+		if _, err := spool.Append(model.Line{Source: "app", Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := spool.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"one", "two", "three", "four"} {
+		if record := next(t, spool); record.Line.Text != text {
+			t.Fatalf("Expected %q, received %+v", text, record)
+		}
+	}
+	// Acknowledge out of order, "two" is still pending
+	if err := spool.Ack(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := spool.Backlog(); count != 2 {
+		t.Errorf("Expected a backlog of 2 lines, received %d", count)
+	}
+	if err := spool.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	spool, err = Open(dir, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spool.Close()
+	if record := next(t, spool); record.Seq != 2 || record.Line.Text != "two" {
+		t.Fatalf("Expected the pending line two after restart, received %+v", record)
+	}
+	if record := next(t, spool); record.Seq != 4 || record.Line.Text != "four" {
+		t.Fatalf("Expected the pending line four after restart, received %+v", record)
+	}
+	if seq, err := spool.Append(model.Line{Source: "app", Text: "five"}); err != nil || seq != 5 {
+		t.Fatalf("Expected the sequence to continue at 5, received %d (%v)", seq, err)
+	}
+	if err := spool.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if record := next(t, spool); record.Line.Text != "five" {
+		t.Fatalf("Expected the new line five, received %+v", record)
+	}
+}
@@ -0,0 +1,66 @@
+package tailer
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Parser turns a raw log line into the text that gets anchored
+type Parser func(text string) (string, error)
+
+// nginxCombined matches the default nginx "combined" log format
+var nginxCombined = regexp.MustCompile(`^(\S+) \S+ (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-) "([^"]*)" "([^"]*)"`)
+
+// Parsers known by name in the per-source config
+var Parsers = map[string]Parser{
+	"raw":   ParseRaw,
+	"json":  ParseJSON,
+	"nginx": ParseNginx,
+}
+
+// ParserByName looks up a parser, an empty name selects the raw parser
+func ParserByName(name string) (Parser, error) {
+	if name == "" {
+		return ParseRaw, nil
+	}
+	parser, ok := Parsers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown parser %q", name)
+	}
+	return parser, nil
+}
+
+// ParseRaw keeps the line as it is
+func ParseRaw(text string) (string, error) {
+	return text, nil
+}
+
+// ParseJSON validates a JSON formatted line and compacts it
+func ParseJSON(text string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &fields); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(fields)
+	return string(data), err
+}
+
+// ParseNginx converts a line of the nginx combined format into JSON
+func ParseNginx(text string) (string, error) {
+	match := nginxCombined.FindStringSubmatch(text)
+	if match == nil {
+		return "", fmt.Errorf("not an nginx combined log line")
+	}
+	data, err := json.Marshal(map[string]string{
+		"remote_addr":     match[1],
+		"remote_user":     match[2],
+		"time_local":      match[3],
+		"request":         match[4],
+		"status":          match[5],
+		"body_bytes_sent": match[6],
+		"http_referer":    match[7],
+		"http_user_agent": match[8],
+	})
+	return string(data), err
+}
@@ -0,0 +1,128 @@
+package tailer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/checkpoint"
+	"github.com/TheLazarusNetwork/Monitor/model"
+)
+
+// Rotation modes detected by the tailer
+const (
+	RotationRename       = "rename"       // The file was moved away and a new one created at its path
+	RotationCopyTruncate = "copytruncate" // The file was copied and truncated in place
+)
+
+// EventRotation kind of the internal line emitted at a rotation boundary
+const EventRotation = "rotation"
+
+// Rotation internal event recording the boundary between two generations of a file
+type Rotation struct {
+	Event          string `json:"event"`
+	Mode           string `json:"mode"`
+	File           string `json:"file"`
+	Rotated        string `json:"rotated,omitempty"` // Path the previous generation was moved to
+	PreviousInode  uint64 `json:"previous_inode"`
+	PreviousOffset int64  `json:"previous_offset"` // Offset up to which the previous generation was read
+	Inode          uint64 `json:"inode"`
+}
+
+// position tracks where the tailer is in a file so that rotations can be noticed
+type position struct {
+	file   string
+	inode  uint64
+	offset int64
+}
+
+// advance moves past a raw line read up to offset and reports a rotation when the
+// line does not continue the current file but starts a fresh one
+func (current *position) advance(text string, offset int64) *Rotation {
+	previous := *current
+	current.offset = offset
+	if offset == previous.offset+int64(len(text))+1 || offset != int64(len(text))+1 || previous.offset == 0 {
+		return nil
+	}
+
+	rotation := &Rotation{
+		Event:          EventRotation,
+		Mode:           RotationCopyTruncate,
+		File:           current.file,
+		PreviousInode:  previous.inode,
+		PreviousOffset: previous.offset,
+		Inode:          previous.inode,
+	}
+	if info, err := os.Stat(current.file); err == nil {
+		if inode := checkpoint.Inode(info); inode != previous.inode {
+			rotation.Mode = RotationRename
+			rotation.Inode = inode
+			rotation.Rotated = findRotated(current.file, previous.inode)
+		}
+	}
+	current.inode = rotation.Inode
+	return rotation
+}
+
+// findRotated looks next to file for the previous generation moved away by the rotation
+func findRotated(file string, inode uint64) string {
+	candidates, err := filepath.Glob(file + "*")
+	if err != nil {
+		return ""
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && candidate != file && checkpoint.Inode(info) == inode {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Remainder reads the lines written to the previous generation after the tailer switched away from it
+func (rotation *Rotation) Remainder() ([]string, error) {
+	if rotation.Rotated == "" {
+		return nil, nil
+	}
+	file, err := os.Open(rotation.Rotated)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(rotation.PreviousOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var lines []string
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lines = append(lines, strings.TrimRight(line, "\n"))
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
+
+// Line the internal line anchored alongside the log lines of the source
+func (rotation *Rotation) Line(source string) (model.Line, error) {
+	data, err := json.Marshal(rotation)
+	if err != nil {
+		return model.Line{}, err
+	}
+	return model.Line{
+		Source: source,
+		Kind:   EventRotation,
+		File:   rotation.File,
+		Text:   string(data),
+		Time:   time.Now(),
+		Inode:  rotation.Inode,
+	}, nil
+}
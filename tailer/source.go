@@ -0,0 +1,76 @@
+package tailer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultSource label of the source configured through LOG_FILE_PATH alone
+const DefaultSource = "default"
+
+// Source a labelled group of log files sharing the same parser
+type Source struct {
+	Label    string
+	Patterns []string // File paths or glob patterns
+	Parser   Parser
+}
+
+// Match expands the glob patterns of the source into file paths
+func (source *Source) Match() ([]string, error) {
+	var files []string
+	for _, pattern := range source.Patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %v", source.Label, err)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// LoadSources reads the sources from config
+//
+//	LOG_SOURCES = nginx,app
+//	LOG_NGINX_PATHS = /var/log/nginx/*/access.log
+//	LOG_NGINX_PARSER = nginx
+//
+// Without LOG_SOURCES the paths in LOG_FILE_PATH form a single raw source
+func LoadSources() ([]Source, error) {
+	labels := splitList(viper.GetString("LOG_SOURCES"))
+	if len(labels) == 0 {
+		patterns := splitList(viper.GetString("LOG_FILE_PATH"))
+		if len(patterns) == 0 {
+			return nil, fmt.Errorf("neither LOG_SOURCES nor LOG_FILE_PATH is set")
+		}
+		return []Source{{Label: DefaultSource, Patterns: patterns, Parser: ParseRaw}}, nil
+	}
+
+	sources := make([]Source, 0, len(labels))
+	for _, label := range labels {
+		key := "LOG_" + strings.ToUpper(label) + "_"
+		patterns := splitList(viper.GetString(key + "PATHS"))
+		if len(patterns) == 0 {
+			return nil, fmt.Errorf("source %s: %sPATHS is not set", label, key)
+		}
+		parser, err := ParserByName(viper.GetString(key + "PARSER"))
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %v", label, err)
+		}
+		sources = append(sources, Source{Label: label, Patterns: patterns, Parser: parser})
+	}
+	return sources, nil
+}
+
+// splitList splits a comma separated config value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,226 @@
+package tailer
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/checkpoint"
+	"github.com/TheLazarusNetwork/Monitor/model"
+
+	"github.com/nxadm/tail"
+	log "github.com/sirupsen/logrus"
+)
+
+// Tailer follows every file matched by the configured sources, one goroutine per file
+type Tailer struct {
+	sources  []Source
+	interval time.Duration
+	state    *checkpoint.State
+	lines    chan model.Line
+
+	mutex    sync.Mutex
+	tails    map[string]*tail.Tail
+	previous map[string]position // Where tails that ended on their own stopped, the next tail of the file continues from there
+	wait     sync.WaitGroup
+}
+
+// New creates a tailer that rescans the source patterns every interval for new files
+// and resumes every file after the position confirmed in state
+func New(sources []Source, interval time.Duration, state *checkpoint.State) *Tailer {
+	return &Tailer{
+		sources:  sources,
+		interval: interval,
+		state:    state,
+		lines:    make(chan model.Line),
+		tails:    map[string]*tail.Tail{},
+		previous: map[string]position{},
+	}
+}
+
+// Lines the parsed lines of all tailed files
+func (tailer *Tailer) Lines() <-chan model.Line {
+	return tailer.lines
+}
+
+// Run tails all matching files and picks up files that appear later, until ctx is done
+func (tailer *Tailer) Run(ctx context.Context) error {
+	defer close(tailer.lines)
+	if err := tailer.scan(ctx); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(tailer.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := tailer.scan(ctx); err != nil {
+				log.Warnf("Error in scanning log sources: %v", err)
+			}
+		case <-ctx.Done():
+			tailer.stop()
+			return nil
+		}
+	}
+}
+
+// scan starts a tail for every matching file not followed yet
+func (tailer *Tailer) scan(ctx context.Context) error {
+	for i := range tailer.sources {
+		source := &tailer.sources[i]
+		files, err := source.Match()
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			err := tailer.follow(ctx, source, file)
+			if os.IsNotExist(err) {
+				// Gone between the match and opening it, such as in the middle of a rotation, a later scan picks it up
+				log.Debugf("Skipping %s which no longer exists: %v", file, err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// follow starts the tailer goroutine of a single file
+func (tailer *Tailer) follow(ctx context.Context, source *Source, file string) error {
+	tailer.mutex.Lock()
+	defer tailer.mutex.Unlock()
+	if _, ok := tailer.tails[file]; ok {
+		return nil
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	inode := checkpoint.Inode(info)
+	// Reopen the path after rename-and-create rotation, truncation is always followed.
+	// Polling is used as inotify watches can get lost when the file is reopened
+	config := tail.Config{Follow: true, ReOpen: true, MustExist: true, Poll: true}
+	previous, resumed := tailer.previous[file]
+	if resumed {
+		if previous.inode == inode && previous.offset <= info.Size() {
+			config.Location = &tail.SeekInfo{Offset: previous.offset, Whence: io.SeekStart}
+		}
+	} else if position, ok := tailer.state.Get(file); ok && position.Inode == inode && position.Offset <= info.Size() {
+		config.Location = &tail.SeekInfo{Offset: position.Offset, Whence: io.SeekStart}
+	}
+	t, err := tail.TailFile(file, config)
+	if err != nil {
+		return err
+	}
+	tailer.tails[file] = t
+	delete(tailer.previous, file)
+	if config.Location != nil {
+		log.Infof("Tailing %s for source %s from offset %d", file, source.Label, config.Location.Offset)
+	} else {
+		log.Infof("Tailing %s for source %s", file, source.Label)
+	}
+
+	offset := int64(0)
+	if config.Location != nil {
+		offset = config.Location.Offset
+	}
+	current := &position{file: file, inode: inode, offset: offset}
+	if resumed && config.Location == nil {
+		// Another generation replaced the file while it was not tailed, its first line reports the rotation
+		current = &previous
+	}
+	tailer.wait.Add(1)
+	go tailer.read(ctx, source, t, current)
+	return nil
+}
+
+// read forwards the lines of a single file, emitting an event at every rotation boundary
+func (tailer *Tailer) read(ctx context.Context, source *Source, t *tail.Tail, current *position) {
+	defer tailer.wait.Done()
+	defer tailer.forget(ctx, t, current)
+	for line := range t.Lines {
+		if line.Err != nil {
+			log.Warnf("Error in tailing %s: %v", current.file, line.Err)
+			continue
+		}
+		if rotation := current.advance(line.Text, line.SeekInfo.Offset); rotation != nil {
+			log.Infof("Detected %s rotation of %s after offset %d", rotation.Mode, current.file, rotation.PreviousOffset)
+			// Finish reading the previous generation before crossing the file boundary
+			remainder, err := rotation.Remainder()
+			if err != nil {
+				log.Warnf("Error in reading the rest of rotated file %s: %v", rotation.Rotated, err)
+			}
+			offset := rotation.PreviousOffset
+			for _, text := range remainder {
+				offset += int64(len(text)) + 1
+				if !tailer.send(ctx, tailer.parse(source, rotation.File, text, offset, rotation.PreviousInode)) {
+					return
+				}
+			}
+			event, err := rotation.Line(source.Label)
+			if err != nil {
+				log.Warnf("Error in encoding rotation event of %s: %v", current.file, err)
+			} else if !tailer.send(ctx, event) {
+				return
+			}
+		}
+		if !tailer.send(ctx, tailer.parse(source, current.file, line.Text, current.offset, current.inode)) {
+			return
+		}
+	}
+}
+
+// parse runs the parser of the source, unparsable lines are kept as they are
+func (tailer *Tailer) parse(source *Source, file string, raw string, offset int64, inode uint64) model.Line {
+	text, err := source.Parser(raw)
+	if err != nil {
+		log.Warnf("Keeping unparsed line of %s: %v", file, err)
+		text = raw
+	}
+	return model.Line{Source: source.Label, File: file, Text: text, Time: time.Now(), Offset: offset, Inode: inode}
+}
+
+// send forwards a line unless the tailer is shutting down
+func (tailer *Tailer) send(ctx context.Context, line model.Line) bool {
+	select {
+	case tailer.lines <- line:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// forget drops a finished tail so the file is picked up again if it reappears. A tail ends on its own
+// when the file is missing at the wrong moment, such as between the rename and create of a rotation,
+// the next tail of the file then continues from its position so the rotation is still detected
+func (tailer *Tailer) forget(ctx context.Context, t *tail.Tail, current *position) {
+	tailer.mutex.Lock()
+	defer tailer.mutex.Unlock()
+	if tailer.tails[current.file] != t {
+		return
+	}
+	delete(tailer.tails, current.file)
+	if ctx.Err() != nil {
+		return
+	}
+	if err := t.Err(); err != nil {
+		log.Warnf("Tail of %s ended: %v", current.file, err)
+	}
+	tailer.previous[current.file] = *current
+}
+
+// stop ends every tail and waits for their goroutines
+func (tailer *Tailer) stop() {
+	tailer.mutex.Lock()
+	for file, t := range tailer.tails {
+		if err := t.Stop(); err != nil {
+			log.Warnf("Error in stopping tail of %s: %v", file, err)
+		}
+	}
+	tailer.mutex.Unlock()
+	tailer.wait.Wait()
+}
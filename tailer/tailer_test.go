@@ -0,0 +1,284 @@
+package tailer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheLazarusNetwork/Monitor/checkpoint"
+	"github.com/TheLazarusNetwork/Monitor/model"
+
+	"github.com/nxadm/tail/watch"
+)
+
+// TestTailerGlob Files matching a glob are tailed, including files created after startup
+func TestTailerGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, site := range []string{"one", "two"} {
+		if err := os.MkdirAll(filepath.Join(dir, site), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "one", "access.log"), []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source := Source{Label: "nginx", Patterns: []string{filepath.Join(dir, "*", "access.log")}, Parser: ParseRaw}
+	state, err := checkpoint.Load(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailer := New([]Source{source}, 20*time.Millisecond, state)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tailer.Run(ctx)
+
+	expect := func(text string) {
+		select {
+		case line := <-tailer.Lines():
+			if line.Text != text || line.Source != "nginx" {
+				t.Fatalf("Expected line %q of source nginx, received %+v", text, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for line %q", text)
+		}
+	}
+	expect("first")
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "two", "access.log"), []byte("second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expect("second")
+}
+
+// TestTailerVanished A matched file that is gone when it is opened does not stop the other files from being tailed
+func TestTailerVanished(t *testing.T) {
+	dir := t.TempDir()
+	// A dangling link matches the pattern but cannot be opened, like a file removed right after the match
+	if err := os.Symlink(filepath.Join(dir, "removed.log"), filepath.Join(dir, "a.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.log"), []byte("kept\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := checkpoint.Load(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailer := New([]Source{{Label: "app", Patterns: []string{filepath.Join(dir, "*.log")}, Parser: ParseRaw}}, time.Second, state)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	failed := make(chan error, 1)
+	go func() { failed <- tailer.Run(ctx) }()
+
+	select {
+	case line := <-tailer.Lines():
+		if line.Text != "kept" {
+			t.Fatalf("Expected the line of the remaining file, received %+v", line)
+		}
+	case err := <-failed:
+		t.Fatalf("Expected the tailer to keep running, it stopped with %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the remaining file")
+	}
+}
+
+// TestTailerCheckpoint A file is resumed after its checkpointed position
+func TestTailerCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(file, []byte("anchored\npending\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := checkpoint.Load(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Set(file, checkpoint.Position{Offset: int64(len("anchored\n")), Inode: checkpoint.Inode(info)})
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	state, err = checkpoint.Load(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tailer := New([]Source{{Label: "app", Patterns: []string{file}, Parser: ParseRaw}}, time.Second, state)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tailer.Run(ctx)
+
+	select {
+	case line := <-tailer.Lines():
+		if line.Text != "pending" {
+			t.Fatalf("Expected to resume at the pending line, received %q", line.Text)
+		}
+		if line.Offset != info.Size() {
+			t.Errorf("Expected offset %d after the last line, received %d", info.Size(), line.Offset)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the pending line")
+	}
+}
+
+// TestTailerRotation Rename-and-create and copytruncate rotations are followed and recorded
+func TestTailerRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "access.log")
+	if err := ioutil.WriteFile(file, []byte("first line\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := checkpoint.Load(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tailer := New([]Source{{Label: "nginx", Patterns: []string{file}, Parser: ParseRaw}}, time.Second, state)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tailer.Run(ctx)
+
+	next := func() model.Line {
+		select {
+		case line := <-tailer.Lines():
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a line")
+		}
+		return model.Line{}
+	}
+	expectRotation := func(mode string) {
+		line := next()
+		rotation := Rotation{}
+		if err := json.Unmarshal([]byte(line.Text), &rotation); err != nil || line.Kind != EventRotation || rotation.Mode != mode {
+			t.Fatalf("Expected a %s rotation event, received %+v", mode, line)
+		}
+	}
+	if line := next(); line.Text != "first line" {
+		t.Fatalf("Expected the first line, received %+v", line)
+	}
+
+	if err := os.Rename(file, file+".1"); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := os.OpenFile(file+".1", os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotated.WriteString("late line\n"); err != nil {
+		t.Fatal(err)
+	}
+	rotated.Close()
+	time.Sleep(100 * time.Millisecond)
+	if err := ioutil.WriteFile(file, []byte("new\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if line := next(); line.Text != "late line" {
+		t.Fatalf("Expected the rest of the rotated file, received %+v", line)
+	}
+	expectRotation(RotationRename)
+	if line := next(); line.Text != "new" {
+		t.Fatalf("Expected the first line of the new file, received %+v", line)
+	}
+
+	if err := os.Truncate(file, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * watch.POLL_DURATION) // Let the poller see the shrunk file before it grows again
+	if err := ioutil.WriteFile(file, []byte("cut\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expectRotation(RotationCopyTruncate)
+	if line := next(); line.Text != "cut" {
+		t.Fatalf("Expected the first line after truncation, received %+v", line)
+	}
+}
+
+// TestTailerLostTail A tail that ends while the file is rotated is followed again from where it stopped,
+// so the rotation and the rest of the previous generation are still reported
+func TestTailerLostTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "access.log")
+	if err := ioutil.WriteFile(file, []byte("first line\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := checkpoint.Load(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tailer := New([]Source{{Label: "nginx", Patterns: []string{file}, Parser: ParseRaw}}, 100*time.Millisecond, state)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tailer.Run(ctx)
+	next := func() model.Line {
+		select {
+		case line := <-tailer.Lines():
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a line")
+		}
+		return model.Line{}
+	}
+	if line := next(); line.Text != "first line" {
+		t.Fatalf("Expected the first line, received %+v", line)
+	}
+
+	// The tail dies the way it does when the file is missing between the rename and the create
+	if err := os.Rename(file, file+".1"); err != nil {
+		t.Fatal(err)
+	}
+	tailer.mutex.Lock()
+	lost := tailer.tails[file]
+	tailer.mutex.Unlock()
+	lost.Kill(os.ErrNotExist)
+	<-lost.Dead()
+	rotated, err := os.OpenFile(file+".1", os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotated.WriteString("late line\n"); err != nil {
+		t.Fatal(err)
+	}
+	rotated.Close()
+	if err := ioutil.WriteFile(file, []byte("new\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if line := next(); line.Text != "late line" {
+		t.Fatalf("Expected the rest of the rotated file, received %+v", line)
+	}
+	line := next()
+	rotation := Rotation{}
+	if err := json.Unmarshal([]byte(line.Text), &rotation); err != nil || rotation.Mode != RotationRename || rotation.PreviousOffset != int64(len("first line\n")) {
+		t.Fatalf("Expected a rename rotation event after the first line, received %+v", line)
+	}
+	if line := next(); line.Text != "new" {
+		t.Fatalf("Expected the first line of the new file, received %+v", line)
+	}
+}
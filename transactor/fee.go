@@ -0,0 +1,271 @@
+package transactor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// FeeClient the part of the Ethereum client fee strategies need
+type FeeClient interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FeeHistory(ctx context.Context, blocks int, percentiles []float64) (*FeeHistory, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+}
+
+// Fees the pricing of one transaction, GasPrice for legacy chains or GasFeeCap and GasTipCap
+// for chains with EIP-1559 dynamic fees
+type Fees struct {
+	GasPrice  *big.Int
+	GasFeeCap *big.Int
+	GasTipCap *big.Int
+}
+
+// Dynamic tells if the fees are for an EIP-1559 transaction
+func (fees *Fees) Dynamic() bool {
+	return fees.GasFeeCap != nil
+}
+
+// Apply sets the fees on the transaction options
+func (fees *Fees) Apply(auth *bind.TransactOpts) {
+	auth.GasPrice, auth.GasFeeCap, auth.GasTipCap = fees.GasPrice, fees.GasFeeCap, fees.GasTipCap
+}
+
+// String fees in gwei for logging
+func (fees *Fees) String() string {
+	if fees.Dynamic() {
+		return fmt.Sprintf("Max Fee: %s gwei | Tip: %s gwei", FormatGwei(fees.GasFeeCap), FormatGwei(fees.GasTipCap))
+	}
+	return fmt.Sprintf("Gas Price: %s gwei", FormatGwei(fees.GasPrice))
+}
+
+// Pricer source of the price paid to miners, swappable between strategies
+type Pricer interface {
+	// TipCap the priority fee of a dynamic fee transaction
+	TipCap(ctx context.Context) (*big.Int, error)
+	// GasPrice the gas price of a legacy transaction
+	GasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// FeeStrategy prices transactions with dynamic fees on chains supporting EIP-1559
+// and falls back to legacy pricing on chains without it
+type FeeStrategy struct {
+	client    FeeClient
+	pricer    Pricer
+	MaxFeeCap *big.Int // Upper bound of the fee cap and of legacy gas prices, nil for none
+	MaxTipCap *big.Int // Upper bound of the priority fee, nil for none
+}
+
+// NewFeeStrategy creates a fee strategy on top of a pricer
+func NewFeeStrategy(client FeeClient, pricer Pricer) *FeeStrategy {
+	return &FeeStrategy{client: client, pricer: pricer}
+}
+
+// Fees prices the next transaction
+func (strategy *FeeStrategy) Fees(ctx context.Context) (*Fees, error) {
+	head, err := strategy.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if head.BaseFee == nil {
+		gasPrice, err := strategy.pricer.GasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Fees{GasPrice: capped(gasPrice, strategy.MaxFeeCap)}, nil
+	}
+
+	tip, err := strategy.pricer.TipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tip = capped(tip, strategy.MaxTipCap)
+	// Leave room for the base fee to double before the transaction stops being includable
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	feeCap = capped(feeCap, strategy.MaxFeeCap)
+	if feeCap.Cmp(tip) < 0 {
+		tip = feeCap
+	}
+	return &Fees{GasFeeCap: feeCap, GasTipCap: tip}, nil
+}
+
+// EstimateGas estimates the gas of a call and adds a safety margin in percent
+func EstimateGas(ctx context.Context, client FeeClient, call ethereum.CallMsg, margin uint64) (uint64, error) {
+	gas, err := client.EstimateGas(ctx, call)
+	if err != nil {
+		return 0, err
+	}
+	return gas + gas*margin/100, nil
+}
+
+// Calldata gas per byte since EIP-2028
+const (
+	CalldataZeroGas    = 4
+	CalldataNonZeroGas = 16
+)
+
+// CalldataGas the intrinsic gas paid for data in a transaction's calldata
+func CalldataGas(data []byte) uint64 {
+	var gas uint64
+	for _, b := range data {
+		if b == 0 {
+			gas += CalldataZeroGas
+		} else {
+			gas += CalldataNonZeroGas
+		}
+	}
+	return gas
+}
+
+// PricerConfig settings of the pricers
+type PricerConfig struct {
+	FixedTip   *big.Int // Priority fee of the fixed pricer
+	FixedPrice *big.Int // Legacy gas price of the fixed pricer
+	Blocks     int      // Number of recent blocks the percentile pricer looks at
+	Percentile int      // Percentile of the prices paid in those blocks
+}
+
+// NewPricer creates the named pricer: fixed, oracle or percentile
+func NewPricer(name string, client FeeClient, config PricerConfig) (Pricer, error) {
+	switch name {
+	case "fixed":
+		if config.FixedTip == nil || config.FixedPrice == nil {
+			return nil, fmt.Errorf("fixed pricer needs both a tip and a gas price")
+		}
+		return &FixedPricer{Tip: config.FixedTip, Price: config.FixedPrice}, nil
+	case "", "oracle":
+		return &OraclePricer{Client: client}, nil
+	case "percentile":
+		if config.Blocks <= 0 || config.Percentile < 0 || config.Percentile > 100 {
+			return nil, fmt.Errorf("percentile pricer needs a positive block count and a percentile between 0 and 100")
+		}
+		return &PercentilePricer{Client: client, Blocks: config.Blocks, Percentile: config.Percentile}, nil
+	default:
+		return nil, fmt.Errorf("unknown fee strategy %q", name)
+	}
+}
+
+// FixedPricer always pays the same configured price
+type FixedPricer struct {
+	Tip   *big.Int
+	Price *big.Int
+}
+
+// TipCap the configured priority fee
+func (pricer *FixedPricer) TipCap(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(pricer.Tip), nil
+}
+
+// GasPrice the configured gas price
+func (pricer *FixedPricer) GasPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(pricer.Price), nil
+}
+
+// OraclePricer pays what the node's gas price oracle suggests
+type OraclePricer struct {
+	Client FeeClient
+}
+
+// TipCap the priority fee suggested by the node
+func (pricer *OraclePricer) TipCap(ctx context.Context) (*big.Int, error) {
+	return pricer.Client.SuggestGasTipCap(ctx)
+}
+
+// GasPrice the gas price suggested by the node
+func (pricer *OraclePricer) GasPrice(ctx context.Context) (*big.Int, error) {
+	return pricer.Client.SuggestGasPrice(ctx)
+}
+
+// FeeHistory the eth_feeHistory of recent blocks, the node computes the reward percentiles so that
+// blocks with transaction types the pinned block type cannot decode are priced as well
+type FeeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas"` // One more than the blocks, the last is the next block's
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// PercentilePricer pays a percentile of what transactions in recent blocks paid
+type PercentilePricer struct {
+	Client     FeeClient
+	Blocks     int
+	Percentile int
+}
+
+// TipCap percentile of the effective priority fees in recent blocks
+func (pricer *PercentilePricer) TipCap(ctx context.Context) (*big.Int, error) {
+	tip, _, err := pricer.percentile(ctx)
+	return tip, err
+}
+
+// GasPrice percentile of the gas prices in recent blocks, the tips of blocks without a base fee are their whole gas price
+func (pricer *PercentilePricer) GasPrice(ctx context.Context) (*big.Int, error) {
+	tip, baseFee, err := pricer.percentile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Add(tip, baseFee), nil
+}
+
+// percentile the percentile of the per block reward percentiles of the recent non-empty blocks and the next base fee
+func (pricer *PercentilePricer) percentile(ctx context.Context) (*big.Int, *big.Int, error) {
+	history, err := pricer.Client.FeeHistory(ctx, pricer.Blocks, []float64{float64(pricer.Percentile)})
+	if err != nil {
+		return nil, nil, err
+	}
+	baseFee := new(big.Int)
+	if len(history.BaseFee) > 0 && history.BaseFee[len(history.BaseFee)-1] != nil {
+		baseFee = history.BaseFee[len(history.BaseFee)-1].ToInt()
+	}
+	var prices []*big.Int
+	for i, rewards := range history.Reward {
+		if i < len(history.GasUsedRatio) && history.GasUsedRatio[i] == 0 || len(rewards) == 0 || rewards[0] == nil {
+			continue
+		}
+		prices = append(prices, rewards[0].ToInt())
+	}
+	if len(prices) == 0 {
+		// Empty blocks, any price gets included
+		return big.NewInt(params.GWei), baseFee, nil
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+	return prices[(len(prices)-1)*pricer.Percentile/100], baseFee, nil
+}
+
+// capped limits value to max, a nil max leaves it untouched
+func capped(value, max *big.Int) *big.Int {
+	if max != nil && value.Cmp(max) > 0 {
+		return new(big.Int).Set(max)
+	}
+	return value
+}
+
+// ParseGwei parses a decimal amount of gwei into wei, an empty string yields nil
+func ParseGwei(value string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	gwei, ok := new(big.Float).SetString(value)
+	if !ok || gwei.Sign() < 0 {
+		return nil, fmt.Errorf("invalid gwei amount %q", value)
+	}
+	wei, _ := new(big.Float).Mul(gwei, big.NewFloat(params.GWei)).Int(nil)
+	return wei, nil
+}
+
+// FormatGwei formats an amount of wei in gwei
+func FormatGwei(wei *big.Int) string {
+	if wei == nil {
+		return "0"
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Text('f', 2)
+}
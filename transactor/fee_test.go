@@ -0,0 +1,106 @@
+package transactor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// fakeFees node with a fixed head block
+type fakeFees struct {
+	baseFee *big.Int
+}
+
+func (fake *fakeFees) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(1), BaseFee: fake.baseFee}, nil
+}
+
+func (fake *fakeFees) FeeHistory(ctx context.Context, blocks int, percentiles []float64) (*FeeHistory, error) {
+	gwei := func(n int64) *hexutil.Big { return (*hexutil.Big)(big.NewInt(n * params.GWei)) }
+	// The middle block is empty, its zero reward must not pull the percentile down
+	history := &FeeHistory{
+		Reward:       [][]*hexutil.Big{{gwei(3)}, {gwei(0)}, {gwei(1)}},
+		GasUsedRatio: []float64{0.5, 0, 0.9},
+		BaseFee:      []*hexutil.Big{gwei(0), gwei(0), gwei(0), gwei(0)},
+	}
+	if fake.baseFee != nil {
+		history.BaseFee[3] = (*hexutil.Big)(fake.baseFee)
+	}
+	return history, nil
+}
+
+func (fake *fakeFees) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(30 * params.GWei), nil
+}
+
+func (fake *fakeFees) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(2 * params.GWei), nil
+}
+
+func (fake *fakeFees) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 50000, nil
+}
+
+// TestFeeStrategy Dynamic fees are capped on 1559 chains and legacy pricing is used elsewhere
+func TestFeeStrategy(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeFees{baseFee: big.NewInt(40 * params.GWei)}
+	strategy := NewFeeStrategy(client, &OraclePricer{Client: client})
+
+	fees, err := strategy.Fees(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fees.Dynamic() || fees.GasTipCap.Cmp(big.NewInt(2*params.GWei)) != 0 || fees.GasFeeCap.Cmp(big.NewInt(82*params.GWei)) != 0 {
+		t.Errorf("Expected a tip of 2 and a fee cap of 82 gwei, received %s", fees)
+	}
+
+	strategy.MaxFeeCap = big.NewInt(50 * params.GWei)
+	strategy.MaxTipCap = big.NewInt(params.GWei)
+	if fees, err = strategy.Fees(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasTipCap.Cmp(big.NewInt(params.GWei)) != 0 || fees.GasFeeCap.Cmp(big.NewInt(50*params.GWei)) != 0 {
+		t.Errorf("Expected fees capped at a tip of 1 and a fee cap of 50 gwei, received %s", fees)
+	}
+
+	client.baseFee = nil
+	if fees, err = strategy.Fees(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fees.Dynamic() || fees.GasPrice.Cmp(big.NewInt(30*params.GWei)) != 0 {
+		t.Errorf("Expected a legacy gas price of 30 gwei, received %s", fees)
+	}
+
+	percentile := &PercentilePricer{Client: client, Blocks: 3, Percentile: 100}
+	if tip, err := percentile.TipCap(ctx); err != nil || tip.Cmp(big.NewInt(3*params.GWei)) != 0 {
+		t.Errorf("Expected the highest tip of the non-empty blocks, 3 gwei, received %v (%v)", tip, err)
+	}
+	percentile.Percentile = 0
+	if price, err := percentile.GasPrice(ctx); err != nil || price.Cmp(big.NewInt(params.GWei)) != 0 {
+		t.Errorf("Expected a legacy gas price of 1 gwei, received %v (%v)", price, err)
+	}
+
+	if gas, err := EstimateGas(ctx, client, ethereum.CallMsg{}, 20); err != nil || gas != 60000 {
+		t.Errorf("Expected a gas limit of 60000, received %d (%v)", gas, err)
+	}
+}
+
+// TestParseGwei Decimal gwei amounts are converted to wei
+func TestParseGwei(t *testing.T) {
+	wei, err := ParseGwei("1.5")
+	if err != nil || wei.Cmp(big.NewInt(1500000000)) != 0 {
+		t.Errorf("Expected 1500000000 wei, received %v (%v)", wei, err)
+	}
+	if wei, err := ParseGwei(""); err != nil || wei != nil {
+		t.Errorf("Expected nil for an empty amount, received %v (%v)", wei, err)
+	}
+	if _, err := ParseGwei("-1"); err == nil {
+		t.Error("Expected an error for a negative amount")
+	}
+}
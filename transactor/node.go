@@ -0,0 +1,51 @@
+package transactor
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Node an Ethereum client along with the raw calls whose results the pinned go-ethereum types cannot reproduce,
+// such as the hashes of blocks with fields newer than London
+type Node struct {
+	*ethclient.Client
+	rpc *rpc.Client
+}
+
+// Dial connects to an Ethereum node over HTTP, websocket or IPC
+func Dial(endpoint string) (*Node, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Client: ethclient.NewClient(client), rpc: client}, nil
+}
+
+// FeeHistory the base fees and the reward percentiles of the latest blocks as computed by the node
+func (node *Node) FeeHistory(ctx context.Context, blocks int, percentiles []float64) (*FeeHistory, error) {
+	history := &FeeHistory{}
+	if err := node.rpc.CallContext(ctx, history, "eth_feeHistory", hexutil.Uint(blocks), "latest", percentiles); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// BlockHash the hash of the canonical block at number as reported by the node, not recomputed from the header
+func (node *Node) BlockHash(ctx context.Context, number *big.Int) (common.Hash, error) {
+	var block *struct {
+		Hash common.Hash `json:"hash"`
+	}
+	if err := node.rpc.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeBig(number), false); err != nil {
+		return common.Hash{}, err
+	}
+	if block == nil {
+		return common.Hash{}, ethereum.NotFound
+	}
+	return block.Hash, nil
+}
@@ -0,0 +1,150 @@
+package transactor
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// NonceReader the part of the Ethereum client the nonce manager needs
+type NonceReader interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// NonceManager hands out sequential nonces of one address locally so that many transactions
+// can be pending at once without asking the node for every transaction
+type NonceManager struct {
+	client  NonceReader
+	address common.Address
+
+	mutex  sync.Mutex
+	synced bool
+	next   uint64   // Next fresh nonce
+	mined  uint64   // Nonces below this one are mined
+	gaps   []uint64 // Handed out nonces that never reached the node, reused first
+}
+
+// NewNonceManager creates the nonce manager of an address, it syncs with the chain on first use
+func NewNonceManager(client NonceReader, address common.Address) *NonceManager {
+	return &NonceManager{client: client, address: address}
+}
+
+// Address the account the nonces belong to
+func (manager *NonceManager) Address() common.Address {
+	return manager.address
+}
+
+// Next hands out the next nonce, filling gaps left by failed transactions first
+func (manager *NonceManager) Next(ctx context.Context) (uint64, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if !manager.synced {
+		if err := manager.sync(ctx); err != nil {
+			return 0, err
+		}
+	}
+	if len(manager.gaps) > 0 {
+		nonce := manager.gaps[0]
+		manager.gaps = manager.gaps[1:]
+		return nonce, nil
+	}
+	nonce := manager.next
+	manager.next++
+	return nonce, nil
+}
+
+// Fail returns a nonce whose transaction was not accepted by the node. A nonce that is too low or
+// held by a pending transaction means the local view is stale and triggers a resync, any other nonce is reused by Next
+func (manager *NonceManager) Fail(nonce uint64, err error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if IsNonceTooLow(err) {
+		log.Warnf("Nonce %d of %s is too low, resyncing with the chain", nonce, manager.address.Hex())
+		manager.synced = false
+		return
+	}
+	if IsNonceInUse(err) {
+		log.Warnf("Nonce %d of %s is held by a pending transaction, resyncing with the chain: %v", nonce, manager.address.Hex(), err)
+		manager.synced = false
+		return
+	}
+	if nonce+1 == manager.next {
+		manager.next--
+		return
+	}
+	if nonce < manager.next {
+		manager.gaps = append(manager.gaps, nonce)
+		sort.Slice(manager.gaps, func(i, j int) bool { return manager.gaps[i] < manager.gaps[j] })
+	}
+}
+
+// Refresh compares the local view with the node. When the node knows fewer pending transactions
+// than were handed out, transactions were dropped and the gap is handed out again
+func (manager *NonceManager) Refresh(ctx context.Context) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if !manager.synced {
+		return manager.sync(ctx)
+	}
+	mined, err := manager.client.NonceAt(ctx, manager.address, nil)
+	if err != nil {
+		return err
+	}
+	pending, err := manager.client.PendingNonceAt(ctx, manager.address)
+	if err != nil {
+		return err
+	}
+	manager.mined = mined
+	if pending > manager.next {
+		log.Warnf("Nonce %d of %s is ahead of the local nonce %d, resyncing", pending, manager.address.Hex(), manager.next)
+		return manager.sync(ctx)
+	}
+	if pending < manager.next && len(manager.gaps) == 0 {
+		log.Warnf("Detected a nonce gap for %s: node pending nonce %d, local nonce %d", manager.address.Hex(), pending, manager.next)
+		for nonce := pending; nonce < manager.next; nonce++ {
+			manager.gaps = append(manager.gaps, nonce)
+		}
+	}
+	return nil
+}
+
+// Pending number of handed out nonces not mined yet
+func (manager *NonceManager) Pending() uint64 {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if manager.next < manager.mined {
+		return 0
+	}
+	return manager.next - manager.mined
+}
+
+// sync takes the next nonce from the node's pending state
+func (manager *NonceManager) sync(ctx context.Context) error {
+	pending, err := manager.client.PendingNonceAt(ctx, manager.address)
+	if err != nil {
+		return err
+	}
+	mined, err := manager.client.NonceAt(ctx, manager.address, nil)
+	if err != nil {
+		return err
+	}
+	manager.next, manager.mined, manager.gaps, manager.synced = pending, mined, nil, true
+	return nil
+}
+
+// IsNonceTooLow tells if the node rejected a transaction because its nonce was already used
+func IsNonceTooLow(err error) bool {
+	return err != nil && strings.Contains(err.Error(), core.ErrNonceTooLow.Error())
+}
+
+// IsNonceInUse tells if the node rejected a transaction because a pending transaction already holds its nonce
+func IsNonceInUse(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), core.ErrAlreadyKnown.Error()) || strings.Contains(err.Error(), core.ErrReplaceUnderpriced.Error()))
+}
@@ -0,0 +1,73 @@
+package transactor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// fakeNonces node view of the account nonces
+type fakeNonces struct {
+	pending, mined uint64
+	calls          int
+}
+
+func (fake *fakeNonces) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	fake.calls++
+	return fake.pending, nil
+}
+
+func (fake *fakeNonces) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return fake.mined, nil
+}
+
+// TestNonceManager Nonces are handed out locally, gaps are refilled and stale views resynced
+func TestNonceManager(t *testing.T) {
+	node := &fakeNonces{pending: 7, mined: 7}
+	manager := NewNonceManager(node, common.Address{})
+	ctx := context.Background()
+
+	next := func(expected uint64) {
+		nonce, err := manager.Next(ctx)
+		if err != nil || nonce != expected {
+			t.Fatalf("Expected nonce %d, received %d (%v)", expected, nonce, err)
+		}
+	}
+	next(7)
+	next(8)
+	next(9)
+	if node.calls != 1 {
+		t.Errorf("Expected a single sync with the node, received %d", node.calls)
+	}
+
+	// A failed transaction in the middle leaves a gap that is filled first
+	manager.Fail(8, errors.New("connection refused"))
+	next(8)
+	next(10)
+
+	// The node dropped everything after 9
+	node.pending = 9
+	if err := manager.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	next(9)
+	next(10)
+	next(11)
+
+	// Another sender used our nonces
+	manager.Fail(12, errors.New(core.ErrNonceTooLow.Error()))
+	node.pending = 20
+	next(20)
+
+	// Nonces held by pending transactions are not handed out again
+	manager.Fail(20, errors.New(core.ErrReplaceUnderpriced.Error()))
+	node.pending = 21
+	next(21)
+	manager.Fail(21, errors.New(core.ErrAlreadyKnown.Error()))
+	node.pending = 22
+	next(22)
+}
@@ -0,0 +1,138 @@
+package transactor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// PriceBump minimum fee increase in percent for the node to accept a replacement transaction
+const PriceBump = 10
+
+// ReplaceClient the part of the Ethereum client replacing transactions needs
+type ReplaceClient interface {
+	FeeClient
+	ChainID(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// Replacer re-sends pending transactions with the same nonce at a higher fee
+type Replacer struct {
+	client ReplaceClient
+	fees   *FeeStrategy
+	from   common.Address
+	signer bind.SignerFn
+}
+
+// NewReplacer creates a replacer signing for the from account
+func NewReplacer(client ReplaceClient, fees *FeeStrategy, from common.Address, signer bind.SignerFn) *Replacer {
+	return &Replacer{client: client, fees: fees, from: from, signer: signer}
+}
+
+// SpeedUp re-sends a pending transaction unchanged except for its fees
+func (replacer *Replacer) SpeedUp(ctx context.Context, previous *types.Transaction) (*types.Transaction, error) {
+	current, err := replacer.fees.Fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fees, err := replacer.bump(previous, current)
+	if err != nil {
+		return nil, err
+	}
+	return replacer.send(ctx, previous.Nonce(), previous.To(), previous.Value(), previous.Gas(), previous.Data(), fees)
+}
+
+// Cancel replaces a pending transaction with a zero-value transfer to the sender itself, paying the
+// current fees raised by bump percent and at least PriceBump percent more than the pending transaction
+func (replacer *Replacer) Cancel(ctx context.Context, previous *types.Transaction, bump int64) (*types.Transaction, error) {
+	current, err := replacer.fees.Fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fees, err := replacer.bump(previous, &Fees{
+		GasPrice:  raise(current.GasPrice, bump),
+		GasFeeCap: raise(current.GasFeeCap, bump),
+		GasTipCap: raise(current.GasTipCap, bump),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replacer.send(ctx, previous.Nonce(), &replacer.from, big.NewInt(0), params.TxGas, nil, fees)
+}
+
+// bump raises the fees of previous by at least PriceBump percent, current fees above that are kept
+func (replacer *Replacer) bump(previous *types.Transaction, current *Fees) (*Fees, error) {
+	var fees *Fees
+	if current.Dynamic() {
+		fees = &Fees{
+			GasFeeCap: maximum(current.GasFeeCap, raise(previous.GasFeeCap(), PriceBump)),
+			GasTipCap: maximum(current.GasTipCap, raise(previous.GasTipCap(), PriceBump)),
+		}
+		if cap := replacer.fees.MaxFeeCap; cap != nil && fees.GasFeeCap.Cmp(cap) > 0 {
+			return nil, fmt.Errorf("replacement fee cap %s gwei exceeds the maximum of %s gwei", FormatGwei(fees.GasFeeCap), FormatGwei(cap))
+		}
+	} else {
+		fees = &Fees{GasPrice: maximum(current.GasPrice, raise(previous.GasPrice(), PriceBump))}
+		if cap := replacer.fees.MaxFeeCap; cap != nil && fees.GasPrice.Cmp(cap) > 0 {
+			return nil, fmt.Errorf("replacement gas price %s gwei exceeds the maximum of %s gwei", FormatGwei(fees.GasPrice), FormatGwei(cap))
+		}
+	}
+	return fees, nil
+}
+
+// send signs and broadcasts a transaction with explicit nonce and fees
+func (replacer *Replacer) send(ctx context.Context, nonce uint64, to *common.Address, value *big.Int, gas uint64, data []byte, fees *Fees) (*types.Transaction, error) {
+	var unsigned *types.Transaction
+	if fees.Dynamic() {
+		chainID, err := replacer.client.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		unsigned = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			GasTipCap: fees.GasTipCap,
+			GasFeeCap: fees.GasFeeCap,
+			Gas:       gas,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		})
+	} else {
+		unsigned = types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: fees.GasPrice,
+			Gas:      gas,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		})
+	}
+	tx, err := replacer.signer(replacer.from, unsigned)
+	if err != nil {
+		return nil, err
+	}
+	return tx, replacer.client.SendTransaction(ctx, tx)
+}
+
+// raise increases value by percent, rounding up
+func raise(value *big.Int, percent int64) *big.Int {
+	if value == nil {
+		return nil
+	}
+	raised := new(big.Int).Mul(value, big.NewInt(100+percent))
+	raised.Add(raised, big.NewInt(99))
+	return raised.Div(raised, big.NewInt(100))
+}
+
+func maximum(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
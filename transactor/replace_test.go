@@ -0,0 +1,47 @@
+package transactor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+type fakeReplace struct {
+	fakeFees
+	sent []*types.Transaction
+}
+
+func (fake *fakeReplace) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1337), nil
+}
+
+func (fake *fakeReplace) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	fake.sent = append(fake.sent, tx)
+	return nil
+}
+
+// TestReplacerCancel A cancellation outbids the pending transaction by at least PriceBump, even above the current fees
+func TestReplacerCancel(t *testing.T) {
+	client := &fakeReplace{fakeFees: fakeFees{baseFee: big.NewInt(40 * params.GWei)}}
+	from := common.Address{1}
+	replacer := NewReplacer(client, NewFeeStrategy(client, &OraclePricer{Client: client}), from, func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		return tx, nil
+	})
+
+	// Sent while fees were higher than they are now, 82 gwei fee cap and 2 gwei tip
+	previous := types.NewTx(&types.DynamicFeeTx{Nonce: 7, GasFeeCap: big.NewInt(100 * params.GWei), GasTipCap: big.NewInt(5 * params.GWei), Gas: 60000})
+	tx, err := replacer.Cancel(context.Background(), previous, 10)
+	if err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+	if tx.Nonce() != 7 || *tx.To() != from || tx.Value().Sign() != 0 || tx.Gas() != params.TxGas {
+		t.Errorf("Expected a zero-value transfer to the sender at nonce 7, received %+v", tx)
+	}
+	if tx.GasFeeCap().Cmp(big.NewInt(110*params.GWei)) != 0 || tx.GasTipCap().Cmp(big.NewInt(5500*params.GWei/1000)) != 0 {
+		t.Errorf("Expected the pending fees raised by 10%%, received a fee cap of %s and a tip of %s", tx.GasFeeCap(), tx.GasTipCap())
+	}
+}
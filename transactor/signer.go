@@ -0,0 +1,123 @@
+package transactor
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signer signs the agent's transactions, with a key held in process or by an external service
+type Signer interface {
+	Address() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	SignText(text []byte) ([]byte, error) // EIP-191 personal message signature with a 0/1 recovery id
+}
+
+// Transactor builds transaction options signing through signer for a chain
+func Transactor(signer Signer, chainID *big.Int) *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From: signer.Address(),
+		Signer: func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if from != signer.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.SignTx(tx, chainID)
+		},
+	}
+}
+
+// KeySigner signs with a private key held by the agent
+type KeySigner struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewKeySigner creates a signer for a private key
+func NewKeySigner(privateKey *ecdsa.PrivateKey) *KeySigner {
+	return &KeySigner{privateKey: privateKey}
+}
+
+// Address the address of the key
+func (signer *KeySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(signer.privateKey.PublicKey)
+}
+
+// PublicKey the public key of the key
+func (signer *KeySigner) PublicKey() *ecdsa.PublicKey {
+	return &signer.privateKey.PublicKey
+}
+
+// SignTx signs a transaction for the chain
+func (signer *KeySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), signer.privateKey)
+}
+
+// SignText signs an EIP-191 personal message
+func (signer *KeySigner) SignText(text []byte) ([]byte, error) {
+	return crypto.Sign(accounts.TextHash(text), signer.privateKey)
+}
+
+// ExternalSigner signs through a signing daemon speaking clef's account_signTransaction JSON-RPC API,
+// so the agent never holds a key that can spend funds
+type ExternalSigner struct {
+	external *external.ExternalSigner
+	account  accounts.Account
+}
+
+// NewExternalSigner connects to the signing daemon at endpoint, an HTTP URL or IPC path, to sign as address
+func NewExternalSigner(endpoint string, address common.Address) (*ExternalSigner, error) {
+	signer, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("external signer %s: %v", endpoint, err)
+	}
+	return &ExternalSigner{external: signer, account: accounts.Account{Address: address}}, nil
+}
+
+// Address the account the daemon signs as
+func (signer *ExternalSigner) Address() common.Address {
+	return signer.account.Address
+}
+
+// SignTx asks the daemon to sign a transaction and checks it came back unchanged and signed by the account
+func (signer *ExternalSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signed, err := signer.external.SignTx(signer.account, tx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("external signer: %v", err)
+	}
+	if signed == nil {
+		return nil, fmt.Errorf("external signer returned no transaction")
+	}
+	// The daemon may adjust requests, only the exact transaction asked for is accepted
+	if signed.Nonce() != tx.Nonce() || signed.Gas() != tx.Gas() || signed.Value().Cmp(tx.Value()) != 0 ||
+		signed.GasFeeCap().Cmp(tx.GasFeeCap()) != 0 || signed.GasTipCap().Cmp(tx.GasTipCap()) != 0 ||
+		(signed.To() == nil) != (tx.To() == nil) || (tx.To() != nil && *signed.To() != *tx.To()) ||
+		string(signed.Data()) != string(tx.Data()) {
+		return nil, fmt.Errorf("external signer changed transaction %s", tx.Hash().Hex())
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		return nil, fmt.Errorf("external signer: %v", err)
+	}
+	if sender != signer.account.Address {
+		return nil, fmt.Errorf("external signer signed as %s instead of %s", sender.Hex(), signer.account.Address.Hex())
+	}
+	return signed, nil
+}
+
+// SignText asks the daemon to sign an EIP-191 personal message through account_signData
+func (signer *ExternalSigner) SignText(text []byte) ([]byte, error) {
+	signature, err := signer.external.SignText(signer.account, text)
+	if err != nil {
+		return nil, fmt.Errorf("external signer: %v", err)
+	}
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("external signer returned a signature of %d bytes", len(signature))
+	}
+	return signature, nil
+}
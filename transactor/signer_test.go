@@ -0,0 +1,119 @@
+package transactor
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// stubSigner the account namespace of a clef compatible signing daemon, signing with its own key
+type stubSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// SignResult the response of account_signTransaction
+type SignResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+func (stub *stubSigner) Version() string {
+	return "6.0.0"
+}
+
+func (stub *stubSigner) List() []common.Address {
+	return []common.Address{crypto.PubkeyToAddress(stub.key.PublicKey)}
+}
+
+func (stub *stubSigner) SignTransaction(args apitypes.SendTxArgs) (*SignResult, error) {
+	tx, err := types.SignTx(args.ToTransaction(), types.LatestSignerForChainID(args.ChainID.ToInt()), stub.key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &SignResult{Raw: raw, Tx: tx}, nil
+}
+
+func (stub *stubSigner) SignData(contentType string, address common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
+	signature, err := crypto.Sign(accounts.TextHash(data), stub.key)
+	if err != nil {
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27 // clef returns Ethereum style recovery ids
+	return signature, nil
+}
+
+// startStub serves a stub signing daemon over HTTP
+func startStub(t *testing.T, key *ecdsa.PrivateKey) string {
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", &stubSigner{key: key}); err != nil {
+		t.Fatalf("Failed to register stub signer: %v", err)
+	}
+	http := httptest.NewServer(server)
+	t.Cleanup(func() {
+		http.Close()
+		server.Stop()
+	})
+	return http.URL
+}
+
+// TestExternalSigner Transactions signed by the daemon are accepted only when signed by the expected account
+func TestExternalSigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(4)
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     7,
+		GasTipCap: big.NewInt(2e9),
+		GasFeeCap: big.NewInt(30e9),
+		Gas:       60000,
+		To:        &common.Address{1},
+		Value:     big.NewInt(0),
+		Data:      []byte{0x01, 0x02},
+	})
+
+	signer, err := NewExternalSigner(startStub(t, key), address)
+	if err != nil {
+		t.Fatalf("Failed to connect to stub signer: %v", err)
+	}
+	signed, err := Transactor(signer, chainID).Signer(address, tx)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil || sender != address || signed.Nonce() != 7 {
+		t.Errorf("Expected a transaction signed by %s, received one from %s: %v", address.Hex(), sender.Hex(), err)
+	}
+
+	// A daemon holding another key must not be trusted
+	other, _ := crypto.GenerateKey()
+	impostor, err := NewExternalSigner(startStub(t, other), address)
+	if err != nil {
+		t.Fatalf("Failed to connect to stub signer: %v", err)
+	}
+	if _, err := impostor.SignTx(tx, chainID); err == nil {
+		t.Error("Expected a transaction signed by another account to be rejected")
+	}
+
+	signature, err := signer.SignText([]byte("statement"))
+	if err != nil {
+		t.Fatalf("Failed to sign text: %v", err)
+	}
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte("statement")), signature)
+	if err != nil || crypto.PubkeyToAddress(*publicKey) != address {
+		t.Errorf("Expected a text signature recovering to %s: %v", address.Hex(), err)
+	}
+}
@@ -0,0 +1,301 @@
+package transactor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Status of a tracked transaction
+type Status string
+
+// Statuses a tracked transaction goes through
+const (
+	StatusPending   Status = "pending"
+	StatusConfirmed Status = "confirmed"
+	StatusFailed    Status = "failed"
+)
+
+// maxAttempts submissions of an entry before it is marked failed
+const maxAttempts = 5
+
+// ReceiptClient the part of the Ethereum client the tracker needs
+type ReceiptClient interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BlockHash(ctx context.Context, number *big.Int) (common.Hash, error)
+}
+
+// Receipt the outcome of a tracked entry
+type Receipt struct {
+	Status      Status      `json:"status"`
+	TxHash      common.Hash `json:"tx"`
+	BlockNumber uint64      `json:"block"`
+	BlockHash   common.Hash `json:"block_hash"`
+	TxIndex     uint        `json:"tx_index"`
+	Attempts    int         `json:"attempts"`
+	// Signed versions of a pending transaction sharing its nonce, latest last, any of them may still be mined
+	Transactions []hexutil.Bytes `json:"transactions,omitempty"`
+}
+
+// Resubmit sends an entry again after its previous transaction was dropped, reorged out or reverted
+type Resubmit func(ctx context.Context, previous *types.Transaction) (*types.Transaction, error)
+
+// Entry a transaction followed by the tracker until it is confirmed or failed
+type Entry struct {
+	Tx       *types.Transaction
+	SentAt   time.Time
+	replaced []*types.Transaction // Earlier versions with the same nonce, any of them may still be mined
+	resubmit Resubmit
+	done     func(*Receipt)
+	record   func(*Receipt)
+	mined    *types.Receipt
+	attempts int
+}
+
+// Tracker waits for transactions to reach a confirmation depth, resubmitting the ones
+// that were dropped from the mempool or reorged out of the chain
+type Tracker struct {
+	client        ReceiptClient
+	confirmations uint64
+	dropTimeout   time.Duration
+	replacer      *Replacer
+	stuckAfter    time.Duration
+
+	mutex   sync.Mutex
+	entries []*Entry
+}
+
+// NewTracker creates a tracker, transactions unknown to the node for dropTimeout count as dropped
+func NewTracker(client ReceiptClient, confirmations uint64, dropTimeout time.Duration) *Tracker {
+	if confirmations == 0 {
+		confirmations = 1
+	}
+	return &Tracker{client: client, confirmations: confirmations, dropTimeout: dropTimeout}
+}
+
+// ReplaceStuck lets the tracker speed up transactions pending for longer than after
+func (tracker *Tracker) ReplaceStuck(replacer *Replacer, after time.Duration) {
+	tracker.replacer, tracker.stuckAfter = replacer, after
+}
+
+// Track follows a sent transaction, done is called once it is confirmed or failed for good
+func (tracker *Tracker) Track(tx *types.Transaction, resubmit Resubmit, done func(*Receipt)) {
+	tracker.TrackRecorded(tx, resubmit, done, nil)
+}
+
+// TrackRecorded follows a sent transaction like Track, record is called with a pending receipt for
+// the transaction and every later version of it, so tracking can be resumed after a restart
+func (tracker *Tracker) TrackRecorded(tx *types.Transaction, resubmit Resubmit, done, record func(*Receipt)) {
+	tracker.add(&Entry{Tx: tx, SentAt: time.Now(), resubmit: resubmit, done: done, record: record, attempts: 1})
+}
+
+// Resume follows again the transaction of a pending receipt recorded before a restart
+func (tracker *Tracker) Resume(pending *Receipt, resubmit Resubmit, done, record func(*Receipt)) error {
+	if len(pending.Transactions) == 0 {
+		return fmt.Errorf("pending receipt of %s holds no transaction", pending.TxHash.Hex())
+	}
+	versions := make([]*types.Transaction, len(pending.Transactions))
+	for i, data := range pending.Transactions {
+		versions[i] = new(types.Transaction)
+		if err := versions[i].UnmarshalBinary(data); err != nil {
+			return err
+		}
+	}
+	last := len(versions) - 1
+	tracker.add(&Entry{Tx: versions[last], SentAt: time.Now(), replaced: versions[:last], resubmit: resubmit, done: done, record: record, attempts: pending.Attempts})
+	return nil
+}
+
+// add starts tracking an entry and records it as pending
+func (tracker *Tracker) add(entry *Entry) {
+	tracker.mutex.Lock()
+	tracker.entries = append(tracker.entries, entry)
+	tracker.mutex.Unlock()
+	tracker.recordPending(entry)
+}
+
+// recordPending reports the versions of an entry sent so far
+func (tracker *Tracker) recordPending(entry *Entry) {
+	if entry.record == nil {
+		return
+	}
+	receipt := &Receipt{Status: StatusPending, TxHash: entry.Tx.Hash(), Attempts: entry.attempts}
+	for _, tx := range append(append([]*types.Transaction{}, entry.replaced...), entry.Tx) {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			log.Warnf("Error in recording transaction %s: %v", tx.Hash().Hex(), err)
+			return
+		}
+		receipt.Transactions = append(receipt.Transactions, data)
+	}
+	entry.record(receipt)
+}
+
+// Pending number of tracked transactions
+func (tracker *Tracker) Pending() int {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	return len(tracker.entries)
+}
+
+// Run polls the receipts of tracked transactions every interval until ctx is done
+func (tracker *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := tracker.Poll(ctx); err != nil {
+				log.Warnf("Error in tracking transactions: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Poll checks every tracked transaction once
+func (tracker *Tracker) Poll(ctx context.Context) error {
+	head, err := tracker.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	tracker.mutex.Lock()
+	entries := tracker.entries
+	tracker.mutex.Unlock()
+
+	var remaining []*Entry
+	for _, entry := range entries {
+		finished, err := tracker.check(ctx, head.Number.Uint64(), entry)
+		if err != nil {
+			log.Warnf("Error in checking transaction %s: %v", entry.Tx.Hash().Hex(), err)
+		}
+		if !finished {
+			remaining = append(remaining, entry)
+		}
+	}
+
+	tracker.mutex.Lock()
+	// Keep entries tracked while polling
+	remaining = append(remaining, tracker.entries[len(entries):]...)
+	tracker.entries = remaining
+	tracker.mutex.Unlock()
+	return nil
+}
+
+// check moves one entry forward, it reports whether the entry is finished
+func (tracker *Tracker) check(ctx context.Context, head uint64, entry *Entry) (bool, error) {
+	receipt, err := tracker.receipt(ctx, entry)
+	if err == ethereum.NotFound {
+		if entry.mined != nil {
+			log.Warnf("Transaction %s was reorged out of block %d", entry.Tx.Hash().Hex(), entry.mined.BlockNumber.Uint64())
+			entry.mined = nil
+			return tracker.resubmit(ctx, entry)
+		}
+		if tracker.replacer != nil && time.Since(entry.SentAt) >= tracker.stuckAfter {
+			finished, err := tracker.speedUp(ctx, entry)
+			if err == nil {
+				return finished, nil
+			}
+			// A speed-up refused for instance above the fee cap must not keep a dropped transaction from being resent
+			log.Warnf("Error in speeding up transaction %s: %v", entry.Tx.Hash().Hex(), err)
+		}
+		if time.Since(entry.SentAt) < tracker.dropTimeout {
+			return false, nil
+		}
+		if _, _, err := tracker.client.TransactionByHash(ctx, entry.Tx.Hash()); err != ethereum.NotFound {
+			return false, err
+		}
+		log.Warnf("Transaction %s was dropped from the mempool", entry.Tx.Hash().Hex())
+		return tracker.resubmit(ctx, entry)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if entry.mined != nil && entry.mined.BlockHash != receipt.BlockHash {
+		log.Warnf("Transaction %s moved from block %d to block %d in a reorg", entry.Tx.Hash().Hex(), entry.mined.BlockNumber.Uint64(), receipt.BlockNumber.Uint64())
+	}
+	entry.mined = receipt
+	if receipt.Status == types.ReceiptStatusFailed {
+		log.Warnf("Transaction %s reverted in block %d", entry.Tx.Hash().Hex(), receipt.BlockNumber.Uint64())
+		entry.mined = nil
+		return tracker.resubmit(ctx, entry)
+	}
+	if head+1 < receipt.BlockNumber.Uint64()+tracker.confirmations {
+		return false, nil
+	}
+
+	// Make sure the block is still part of the canonical chain at the confirmation depth, comparing the
+	// node's own hash since headers of later forks do not hash the same through the pinned header type
+	canonical, err := tracker.client.BlockHash(ctx, receipt.BlockNumber)
+	if err != nil {
+		return false, err
+	}
+	if canonical != receipt.BlockHash {
+		return false, nil
+	}
+	entry.done(&Receipt{
+		Status:      StatusConfirmed,
+		TxHash:      receipt.TxHash,
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		BlockHash:   receipt.BlockHash,
+		TxIndex:     receipt.TransactionIndex,
+		Attempts:    entry.attempts,
+	})
+	return true, nil
+}
+
+// receipt of whichever version of the entry's transaction got mined
+func (tracker *Tracker) receipt(ctx context.Context, entry *Entry) (*types.Receipt, error) {
+	receipt, err := tracker.client.TransactionReceipt(ctx, entry.Tx.Hash())
+	for i := len(entry.replaced) - 1; err == ethereum.NotFound && i >= 0; i-- {
+		receipt, err = tracker.client.TransactionReceipt(ctx, entry.replaced[i].Hash())
+	}
+	return receipt, err
+}
+
+// speedUp replaces a stuck transaction with the same nonce at a higher fee
+func (tracker *Tracker) speedUp(ctx context.Context, entry *Entry) (bool, error) {
+	tx, err := tracker.replacer.SpeedUp(ctx, entry.Tx)
+	if IsNonceTooLow(err) {
+		// Something else took the nonce, none of the versions will be mined
+		return tracker.resubmit(ctx, entry)
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Infof("Sped up transaction %s pending for %s as %s", entry.Tx.Hash().Hex(), time.Since(entry.SentAt).Round(time.Second), tx.Hash().Hex())
+	entry.replaced = append(entry.replaced, entry.Tx)
+	entry.Tx, entry.SentAt = tx, time.Now()
+	tracker.recordPending(entry)
+	return false, nil
+}
+
+// resubmit sends the entry again, entries exceeding maxAttempts are marked failed
+func (tracker *Tracker) resubmit(ctx context.Context, entry *Entry) (bool, error) {
+	if entry.attempts >= maxAttempts {
+		log.Errorf("Giving up on transaction %s after %d attempts", entry.Tx.Hash().Hex(), entry.attempts)
+		entry.done(&Receipt{Status: StatusFailed, TxHash: entry.Tx.Hash(), Attempts: entry.attempts})
+		return true, nil
+	}
+	tx, err := entry.resubmit(ctx, entry.Tx)
+	if err != nil {
+		return false, err
+	}
+	log.Infof("Resubmitted transaction %s as %s", entry.Tx.Hash().Hex(), tx.Hash().Hex())
+	entry.Tx, entry.SentAt, entry.replaced = tx, time.Now(), nil
+	entry.attempts++
+	tracker.recordPending(entry)
+	return false, nil
+}
@@ -0,0 +1,221 @@
+package transactor
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeChain node with receipts and canonical block hashes set by the test
+type fakeChain struct {
+	head     uint64
+	receipts map[common.Hash]*types.Receipt
+	hashes   map[uint64]common.Hash // Block hashes as the node reports them, unrelated to the local header hash
+}
+
+func (fake *fakeChain) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	if receipt, ok := fake.receipts[hash]; ok {
+		return receipt, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (fake *fakeChain) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	return nil, false, ethereum.NotFound
+}
+
+func (fake *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = new(big.Int).SetUint64(fake.head)
+	}
+	return fake.header(number.Uint64()), nil
+}
+
+func (fake *fakeChain) BlockHash(ctx context.Context, number *big.Int) (common.Hash, error) {
+	if hash, ok := fake.hashes[number.Uint64()]; ok {
+		return hash, nil
+	}
+	return common.Hash{}, ethereum.NotFound
+}
+
+func (fake *fakeChain) header(number uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(number)}
+}
+
+func (fake *fakeChain) mine(tx *types.Transaction, number uint64) {
+	if fake.hashes == nil {
+		fake.hashes = map[uint64]common.Hash{}
+	}
+	if _, ok := fake.hashes[number]; !ok {
+		fake.hashes[number] = common.BigToHash(new(big.Int).SetUint64(number + 1000))
+	}
+	fake.receipts[tx.Hash()] = &types.Receipt{
+		Status:           types.ReceiptStatusSuccessful,
+		TxHash:           tx.Hash(),
+		BlockNumber:      new(big.Int).SetUint64(number),
+		BlockHash:        fake.hashes[number],
+		TransactionIndex: 3,
+	}
+}
+
+// TestTracker Transactions are confirmed at depth, reorged out or dropped ones are resubmitted
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	node := &fakeChain{head: 100, receipts: map[common.Hash]*types.Receipt{}}
+	tracker := NewTracker(node, 3, time.Hour)
+
+	first := types.NewTransaction(1, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	second := types.NewTransaction(2, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	var resubmitted []*types.Transaction
+	var receipts []*Receipt
+	resubmit := func(ctx context.Context, previous *types.Transaction) (*types.Transaction, error) {
+		resubmitted = append(resubmitted, previous)
+		return second, nil
+	}
+	tracker.Track(first, resubmit, func(receipt *Receipt) { receipts = append(receipts, receipt) })
+
+	// Mined but not deep enough yet
+	node.mine(first, 99)
+	if err := tracker.Poll(ctx); err != nil || len(receipts) != 0 {
+		t.Fatalf("Expected the transaction to stay pending, received %+v (%v)", receipts, err)
+	}
+
+	// Reorged out before reaching the confirmation depth
+	delete(node.receipts, first.Hash())
+	if err := tracker.Poll(ctx); err != nil || len(resubmitted) != 1 || resubmitted[0] != first {
+		t.Fatalf("Expected the reorged transaction to be resubmitted, received %v (%v)", resubmitted, err)
+	}
+
+	node.mine(second, 101)
+	node.head = 103
+	if err := tracker.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 1 || receipts[0].Status != StatusConfirmed || receipts[0].TxHash != second.Hash() ||
+		receipts[0].BlockNumber != 101 || receipts[0].TxIndex != 3 || receipts[0].Attempts != 2 {
+		t.Fatalf("Expected the resubmitted transaction to be confirmed in block 101, received %+v", receipts)
+	}
+	if tracker.Pending() != 0 {
+		t.Errorf("Expected no pending transaction, received %d", tracker.Pending())
+	}
+
+	// Unknown to the node past the drop timeout
+	tracker = NewTracker(node, 3, 0)
+	resubmitted = nil
+	tracker.Track(first, resubmit, func(receipt *Receipt) {})
+	if err := tracker.Poll(ctx); err != nil || len(resubmitted) != 1 {
+		t.Fatalf("Expected the dropped transaction to be resubmitted, received %v (%v)", resubmitted, err)
+	}
+
+	// A speed-up above the fee cap does not keep the dropped transaction from being resubmitted
+	client := &fakeReplace{fakeFees: fakeFees{baseFee: big.NewInt(40 * params.GWei)}}
+	fees := NewFeeStrategy(client, &OraclePricer{Client: client})
+	fees.MaxFeeCap = big.NewInt(params.GWei)
+	tracker = NewTracker(node, 3, 0)
+	tracker.ReplaceStuck(NewReplacer(client, fees, common.Address{1}, func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		return tx, nil
+	}), 0)
+	resubmitted = nil
+	expensive := types.NewTransaction(3, common.Address{}, big.NewInt(0), 21000, big.NewInt(2*params.GWei), nil)
+	tracker.Track(expensive, resubmit, func(receipt *Receipt) {})
+	if err := tracker.Poll(ctx); err != nil || len(resubmitted) != 1 || len(client.sent) != 0 {
+		t.Fatalf("Expected the dropped transaction to be resubmitted after the refused speed-up, received %v (%v)", resubmitted, err)
+	}
+}
+
+// TestTrackerResume Every sent version is recorded as pending and tracking resumes from the record
+func TestTrackerResume(t *testing.T) {
+	ctx := context.Background()
+	node := &fakeChain{head: 100, receipts: map[common.Hash]*types.Receipt{}}
+	tracker := NewTracker(node, 3, 0)
+	first := types.NewTransaction(1, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	second := types.NewTransaction(1, common.Address{}, big.NewInt(0), 21000, big.NewInt(2), nil)
+	resubmit := func(ctx context.Context, previous *types.Transaction) (*types.Transaction, error) { return second, nil }
+	var recorded []*Receipt
+	record := func(receipt *Receipt) { recorded = append(recorded, receipt) }
+	tracker.TrackRecorded(first, resubmit, func(receipt *Receipt) {}, record)
+
+	// Dropped and resubmitted before the restart
+	if err := tracker.Poll(ctx); err != nil {
+		t.Fatalf("Failed to poll: %v", err)
+	}
+	if len(recorded) != 2 || recorded[1].Status != StatusPending || recorded[1].TxHash != second.Hash() || recorded[1].Attempts != 2 {
+		t.Fatalf("Expected both versions to be recorded as pending, received %+v", recorded)
+	}
+
+	var receipts []*Receipt
+	resumed := NewTracker(node, 3, time.Hour)
+	if err := resumed.Resume(recorded[1], resubmit, func(receipt *Receipt) { receipts = append(receipts, receipt) }, record); err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+	node.mine(second, 99)
+	node.head = 101
+	if err := resumed.Poll(ctx); err != nil || len(receipts) != 1 || receipts[0].TxHash != second.Hash() || receipts[0].Attempts != 2 {
+		t.Errorf("Expected the resumed transaction to be confirmed, received %+v (%v)", receipts, err)
+	}
+}
+
+// TestTrackerCanonicalHash Confirmation compares the block hash the node reports, not the hash of the decoded header
+func TestTrackerCanonicalHash(t *testing.T) {
+	ctx := context.Background()
+	node := &fakeChain{head: 110, receipts: map[common.Hash]*types.Receipt{}}
+	tracker := NewTracker(node, 3, time.Hour)
+	tx := types.NewTransaction(1, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	var receipts []*Receipt
+	tracker.Track(tx, nil, func(receipt *Receipt) { receipts = append(receipts, receipt) })
+
+	// A header with post-London fields hashes differently through the pinned types, the node's hash is authoritative
+	node.mine(tx, 100)
+	if node.header(100).Hash() == node.hashes[100] {
+		t.Fatal("Expected the node hash to differ from the local header hash")
+	}
+	// The block at 100 was replaced on the canonical chain
+	node.hashes[100] = common.Hash{1}
+	if err := tracker.Poll(ctx); err != nil || len(receipts) != 0 {
+		t.Fatalf("Expected a non-canonical block to stay unconfirmed, received %+v (%v)", receipts, err)
+	}
+	node.hashes[100] = node.receipts[tx.Hash()].BlockHash
+	if err := tracker.Poll(ctx); err != nil || len(receipts) != 1 || receipts[0].Status != StatusConfirmed {
+		t.Fatalf("Expected the transaction to be confirmed by the node hash, received %+v (%v)", receipts, err)
+	}
+}
+
+// TestNodeBlockHash The block hash is taken from the node's response as it is
+func TestNodeBlockHash(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &stubBlocks{}); err != nil {
+		t.Fatalf("Failed to register stub node: %v", err)
+	}
+	http := httptest.NewServer(server)
+	defer http.Close()
+	node, err := Dial(http.URL)
+	if err != nil {
+		t.Fatalf("Failed to dial stub node: %v", err)
+	}
+	hash, err := node.BlockHash(context.Background(), big.NewInt(7))
+	if err != nil || hash != common.BigToHash(big.NewInt(7)) {
+		t.Errorf("Expected the hash reported for block 7, received %s (%v)", hash.Hex(), err)
+	}
+	if _, err := node.BlockHash(context.Background(), big.NewInt(8)); err != ethereum.NotFound {
+		t.Errorf("Expected an unknown block to be not found, received %v", err)
+	}
+}
+
+// stubBlocks the eth namespace of a node knowing blocks up to 7
+type stubBlocks struct{}
+
+func (stub *stubBlocks) GetBlockByNumber(number hexutil.Big, full bool) map[string]interface{} {
+	if number.ToInt().Int64() > 7 {
+		return nil
+	}
+	return map[string]interface{}{"hash": common.BigToHash(number.ToInt()), "withdrawalsRoot": common.Hash{}}
+}
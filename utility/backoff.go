@@ -0,0 +1,34 @@
+package utility
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Backoff exponential delay between retries of a failing operation
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Retry calls op until it succeeds or ctx is done, doubling the delay after every failure
+func (backoff Backoff) Retry(ctx context.Context, name string, op func() error) error {
+	delay := backoff.Min
+	for {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		log.Warnf("%s failed, retrying in %s: %v", name, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if delay *= 2; delay > backoff.Max {
+			delay = backoff.Max
+		}
+	}
+}
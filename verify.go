@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TheLazarusNetwork/Monitor/audit"
+	"github.com/TheLazarusNetwork/Monitor/batch"
+	"github.com/TheLazarusNetwork/Monitor/checkpoint"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/merkle"
+	"github.com/TheLazarusNetwork/Monitor/tailer"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Exit codes of the verify command
+const (
+	verifyClean    = 0 // Every line matched
+	verifyMismatch = 1 // Lines were modified or are missing on either side
+	verifyFailed   = 2 // The verification itself could not run
+)
+
+// verify reconciles a local log file with the lines anchored for it and exits with verifyClean, verifyMismatch or verifyFailed
+//
+//	monitor verify -file /var/log/nginx/access.log [-sender 0x..] [-source nginx] [-from 0] [-to latest] [-strict] [-format text|json] [key flags]
+func verify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	file := flags.String("file", "", "local log file to verify")
+	recorded := flags.String("recorded", "", "path of the file as tailed by the agent, the -file path by default")
+	inode := flags.Uint64("inode", 0, "generation of the file to compare, by default the local file's or every generation for a copy")
+	source := flags.String("source", "", "label of the source the file belongs to, only its batches are read")
+	parser := flags.String("parser", "", "parser the agent applies to the file, by default the one configured for the source")
+	sender := flags.String("sender", "", "agent address, the configured agent by default")
+	from := flags.Uint64("from", 0, "first block to read")
+	to := flags.Uint64("to", 0, "last block to read, 0 for the latest block")
+	chunk := flags.Uint64("chunk", audit.DefaultChunk, "blocks queried per request")
+	batches := flags.String("batches", viper.GetString("BATCH_DIR"), "local batch store whose proofs hash-compare batches that cannot be decrypted")
+	strict := flags.Bool("strict", false, "also fail on local lines after the last anchored one, which may not be anchored yet")
+	format := flags.String("format", "text", "report format, text or json")
+	keys := keyFlags(flags)
+	flags.Parse(args)
+
+	if *file == "" {
+		log.Error("verify needs the local -file to reconcile")
+		os.Exit(verifyFailed)
+	}
+	if *format != "text" && *format != "json" {
+		log.Errorf("Unknown format %q, expected text or json", *format)
+		os.Exit(verifyFailed)
+	}
+	if *recorded == "" {
+		*recorded = *file
+	}
+	if *parser == "" && *source != "" {
+		*parser = viper.GetString("LOG_" + strings.ToUpper(*source) + "_PARSER")
+	}
+	parse, err := tailer.ParserByName(*parser)
+	checkVerify("Error in selecting parser:", err)
+	senders, err := parseSenders(*sender)
+	checkVerify("Error in reading sender:", err)
+	decoder, err := keys.decoder()
+	checkVerify("Error in loading decryption keys:", err)
+
+	local, err := os.Open(*file)
+	checkVerify("Error in opening log file:", err)
+	info, err := local.Stat()
+	checkVerify("Error in opening log file:", err)
+	lines, err := audit.ReadLines(local, parse)
+	local.Close()
+	checkVerify("Error in reading log file:", err)
+
+	client, err := ethclient.Dial(viper.Get("INFURA_ENDPOINT").(string))
+	checkVerify("Error in connecting to Infura EndPoint:", err)
+	defer client.Close()
+	ctx := context.Background()
+	if *to == 0 {
+		*to, err = client.BlockNumber(ctx)
+		checkVerify("Error in fetching latest block:", err)
+	}
+	filterer, err := logger.NewLoggerFilterer(common.HexToAddress(viper.Get("LOGGER_CONTRACT_ADDRESS").(string)), client)
+	checkVerify("Unable to load instance of the deployed contract:", err)
+
+	store := &batch.Store{Dir: *batches}
+	reconciler := audit.NewReconciler(*recorded, *inode)
+	err = audit.Walk(ctx, filterer, senders, *from, *to, *chunk, func(event *logger.LoggerLog) error {
+		entry := decoder.Decode(event)
+		if entry.Anchor == nil || *source != "" && entry.Anchor.Source != "" && entry.Anchor.Source != *source {
+			return nil
+		}
+		if entry.Err == nil {
+			reconciler.Add(entry)
+			return nil
+		}
+		// Without the key, the agent's local proofs still tie line hashes to the anchored root
+		leaves := provenLeaves(store, entry.Anchor, *recorded)
+		if leaves == nil {
+			log.Warnf("Skipping batch %s of block %d: %v", entry.Anchor.Root.Hex(), entry.Block, entry.Err)
+			return nil
+		}
+		reconciler.AddProven(leaves, entry.Block, entry.TxHash)
+		return nil
+	})
+	checkVerify("Error in reading Log events:", err)
+
+	report := reconciler.Reconcile(lines, checkpoint.Inode(info))
+	err = printReport(report, *format)
+	checkVerify("Error in writing report:", err)
+	log.Infof("Verified %s against blocks %d to %d: %d matched, %d modified, %d missing on-chain, %d missing locally, %d pending",
+		*file, *from, *to, report.Counts[audit.StatusMatched], report.Counts[audit.StatusModified],
+		report.Counts[audit.StatusMissingOnChain], report.Counts[audit.StatusMissingLocally], report.Counts[audit.StatusPending])
+	if !report.Clean(*strict) {
+		os.Exit(verifyMismatch)
+	}
+	os.Exit(verifyClean)
+}
+
+// checkVerify exits with verifyFailed on error, so failures to verify are told apart from mismatches
+func checkVerify(message string, err error) {
+	if err != nil {
+		log.Errorf("%s %v", message, err)
+		os.Exit(verifyFailed)
+	}
+}
+
+// provenLeaves the leaf hashes of the lines of file in an anchored batch, from the local proofs that
+// verify against its root, nil without proofs
+func provenLeaves(store *batch.Store, anchor *batch.Anchor, file string) []common.Hash {
+	proofs, err := store.LoadProofs(anchor.Key())
+	if os.IsNotExist(err) {
+		// Stores of earlier versions named the files by root
+		proofs, err = store.LoadProofs(anchor.Root)
+	}
+	if err != nil {
+		return nil
+	}
+	var leaves []common.Hash
+	for _, line := range proofs.Lines {
+		// Batches hold the lines of every file of a source, only the verified file's lines are expected locally
+		if line.File == file && merkle.VerifyHash(anchor.Root, line.Leaf, line.Proof) {
+			leaves = append(leaves, line.Leaf)
+		}
+	}
+	return leaves
+}
+
+// printReport writes every result that is not a match, as text or as a JSON document with the counts
+func printReport(report *audit.Report, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "text":
+		for _, result := range report.Results {
+			if result.Status == audit.StatusMatched {
+				continue
+			}
+			location := fmt.Sprintf("offset %d", result.Offset)
+			if result.Number != 0 {
+				location = fmt.Sprintf("line %d", result.Number)
+			}
+			if result.Block != 0 {
+				location += fmt.Sprintf(" (block %d, tx %s)", result.Block, result.TxHash.Hex())
+			}
+			switch result.Status {
+			case audit.StatusModified:
+				fmt.Printf("%s %s\n  local:    %s\n  anchored: %s\n", result.Status, location, result.Local, result.Anchored)
+			case audit.StatusMissingLocally:
+				text := result.Anchored
+				if text == "" {
+					text = "leaf " + result.Leaf.Hex()
+				}
+				fmt.Printf("%s %s: %s\n", result.Status, location, text)
+			default:
+				fmt.Printf("%s %s: %s\n", result.Status, location, result.Local)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, expected text or json", format)
+	}
+}
@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/term"
+)
+
+// KeySource where the signing key is loaded from, the first configured of keystore, key file and mnemonic
+type KeySource struct {
+	Keystore       string // go-ethereum encrypted JSON keystore file
+	PassphraseFile string // File holding the keystore passphrase
+	PassphraseEnv  string // Environment variable holding the keystore passphrase
+	KeyFile        string // File holding the hex encoded private key
+
+	Mnemonic           string
+	MnemonicPassphrase string // Optional BIP-39 passphrase
+	Path               string // Derivation path of the key
+}
+
+// Load loads the signing key and describes where it came from, the description holds no secret
+func (source KeySource) Load() (*ecdsa.PrivateKey, string, error) {
+	switch {
+	case source.Keystore != "":
+		passphrase, err := Passphrase(source.PassphraseFile, source.PassphraseEnv)
+		if err != nil {
+			return nil, "", err
+		}
+		privateKey, err := LoadKeystore(source.Keystore, passphrase)
+		return privateKey, "keystore " + source.Keystore, err
+	case source.KeyFile != "":
+		privateKey, err := crypto.LoadECDSA(source.KeyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("key file %s: %v", source.KeyFile, err)
+		}
+		return privateKey, "key file " + source.KeyFile, nil
+	case source.Mnemonic != "":
+		privateKey, _, path, err := DeriveKey(source.Mnemonic, source.MnemonicPassphrase, source.Path)
+		if err != nil {
+			return nil, "", err
+		}
+		return privateKey, "mnemonic at " + *path, nil
+	default:
+		return nil, "", errors.New("no signing key configured, set a keystore, a key file or a mnemonic")
+	}
+}
+
+// LoadKeystore decrypts a go-ethereum JSON keystore file
+func LoadKeystore(path, passphrase string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("keystore %s: %v", path, err)
+	}
+	return key.PrivateKey, nil
+}
+
+// Passphrase reads a passphrase from a file, else from an environment variable, else from an interactive prompt
+func Passphrase(file, env string) (string, error) {
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if env != "" {
+		if passphrase, ok := os.LookupEnv(env); ok {
+			return passphrase, nil
+		}
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("no keystore passphrase configured and no terminal to prompt for it")
+	}
+	fmt.Fprint(os.Stderr, "Keystore passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(passphrase), err
+}
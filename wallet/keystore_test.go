@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// TestKeySource Keys load from a keystore unlocked through a file or the environment, and from a key file
+func TestKeySource(t *testing.T) {
+	dir := t.TempDir()
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	key := &keystore.Key{Id: uuid.New(), Address: crypto.PubkeyToAddress(privateKey.PublicKey), PrivateKey: privateKey}
+	encrypted, err := keystore.EncryptKey(key, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatalf("Failed to encrypt key: %v", err)
+	}
+	keystorePath := filepath.Join(dir, "keystore.json")
+	passphrasePath := filepath.Join(dir, "passphrase")
+	keyPath := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(keystorePath, encrypted, 0600); err != nil {
+		t.Fatalf("Failed to write keystore: %v", err)
+	}
+	if err := ioutil.WriteFile(passphrasePath, []byte("secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write passphrase: %v", err)
+	}
+	if err := crypto.SaveECDSA(keyPath, privateKey); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	os.Setenv("TEST_KEYSTORE_PASSPHRASE", "secret")
+	defer os.Unsetenv("TEST_KEYSTORE_PASSPHRASE")
+
+	for name, source := range map[string]KeySource{
+		"passphrase file": {Keystore: keystorePath, PassphraseFile: passphrasePath, Mnemonic: mnemonic},
+		"passphrase env":  {Keystore: keystorePath, PassphraseEnv: "TEST_KEYSTORE_PASSPHRASE"},
+		"key file":        {KeyFile: keyPath},
+	} {
+		loaded, _, err := source.Load()
+		if err != nil {
+			t.Fatalf("Failed to load key through %s: %v", name, err)
+		}
+		if !equal(loaded, privateKey) {
+			t.Errorf("Expected the stored key through %s", name)
+		}
+	}
+
+	os.Setenv("TEST_KEYSTORE_PASSPHRASE", "wrong")
+	if _, _, err := (KeySource{Keystore: keystorePath, PassphraseEnv: "TEST_KEYSTORE_PASSPHRASE"}).Load(); err == nil {
+		t.Error("Expected a wrong passphrase to be rejected")
+	}
+}
+
+// equal compares two private keys
+func equal(a, b *ecdsa.PrivateKey) bool {
+	return a.D.Cmp(b.D) == 0
+}
@@ -2,53 +2,112 @@ package wallet
 
 import (
 	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/tyler-smith/go-bip39"
 )
 
-// HDWallet Hierarchical Deterministic Wallet Computation
-func HDWallet(mnemonic string) (*ecdsa.PrivateKey, *ecdsa.PublicKey, *string, error) {
-	// Generate a Bip32 HD wallet for the mnemonic and a user supplied password
-	seed := bip39.NewSeed(mnemonic, "")
-	// Generate a new master node using the seed.
-	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
-	if err != nil {
-		return nil, nil, nil, err
+// Purpose the use a derived key is reserved for, each purpose gets its own BIP-44 account by default
+type Purpose uint32
+
+// Purposes of the agent's keys
+const (
+	Signing    Purpose = 0 // Signs transactions
+	Encryption Purpose = 1 // Opens encrypted batches
+)
+
+// DefaultPaths derivation paths of the keys when none is configured
+var DefaultPaths = map[Purpose]string{
+	Signing:    "m/44H/60H/0H/0/0",
+	Encryption: "m/44H/60H/1H/0/0",
+}
+
+// ErrInvalidMnemonic the mnemonic has unknown words or a wrong checksum
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// DerivationPath the child indexes leading from the master key to a derived key
+type DerivationPath []uint32
+
+// ParsePath parses an absolute BIP-32 path such as m/44H/60H/0H/0/7, hardened indexes are marked with H, h or '
+func ParsePath(path string) (DerivationPath, error) {
+	components := strings.Split(strings.TrimSpace(path), "/")
+	if components[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q does not start at the master key m", path)
 	}
-	//fmt.Println("Master Public Key: ", masterKey.PublicKey())
-	// This gives the path: m/44H
-	acc44H, err := masterKey.Child(hdkeychain.HardenedKeyStart + 44)
-	if err != nil {
-		return nil, nil, nil, err
+	if len(components) == 1 {
+		return nil, fmt.Errorf("derivation path %q has no child indexes", path)
 	}
-	// This gives the path: m/44H/60H
-	acc44H60H, err := acc44H.Child(hdkeychain.HardenedKeyStart + 60)
-	if err != nil {
-		return nil, nil, nil, err
+	var derivation DerivationPath
+	for _, component := range components[1:] {
+		hardened := strings.HasSuffix(component, "H") || strings.HasSuffix(component, "h") || strings.HasSuffix(component, "'")
+		if hardened {
+			component = component[:len(component)-1]
+		}
+		index, err := strconv.ParseUint(component, 10, 32)
+		if err != nil || index >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("derivation path %q has an invalid index %q", path, component)
+		}
+		if hardened {
+			index += hdkeychain.HardenedKeyStart
+		}
+		derivation = append(derivation, uint32(index))
 	}
-	// This gives the path: m/44H/60H/0H
-	acc44H60H0H, err := acc44H60H.Child(hdkeychain.HardenedKeyStart + 0)
-	if err != nil {
-		return nil, nil, nil, err
+	return derivation, nil
+}
+
+// String the path in the m/44H/60H/0H/0/0 notation
+func (path DerivationPath) String() string {
+	var builder strings.Builder
+	builder.WriteString("m")
+	for _, index := range path {
+		if index >= hdkeychain.HardenedKeyStart {
+			fmt.Fprintf(&builder, "/%dH", index-hdkeychain.HardenedKeyStart)
+		} else {
+			fmt.Fprintf(&builder, "/%d", index)
+		}
 	}
-	// This gives the path: m/44H/60H/0H/0
-	acc44H60H0H0, err := acc44H60H0H.Child(0)
+	return builder.String()
+}
+
+// HDWallet Hierarchical Deterministic Wallet Computation of the transaction signing key at the default path
+func HDWallet(mnemonic string) (*ecdsa.PrivateKey, *ecdsa.PublicKey, *string, error) {
+	return DeriveKey(mnemonic, "", DefaultPaths[Signing])
+}
+
+// DeriveKey derives the key at a path from the mnemonic and an optional BIP-39 passphrase
+func DeriveKey(mnemonic, passphrase, path string) (*ecdsa.PrivateKey, *ecdsa.PublicKey, *string, error) {
+	derivation, err := ParsePath(path)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	// This gives the path: m/44H/60H/0H/0/0
-	acc44H60H0H00, err := acc44H60H0H0.Child(0)
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, nil, nil, ErrInvalidMnemonic
+	}
+	// Generate a Bip32 HD wallet for the mnemonic and a user supplied password
+	seed := bip39.NewSeed(mnemonic, passphrase)
+	// Generate a new master node using the seed. The network parameters only matter for
+	// serialising extended keys, the derived private keys are the same on every network
+	key, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	btcecPrivKey, err := acc44H60H0H00.ECPrivKey()
+	for _, index := range derivation {
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	btcecPrivKey, err := key.ECPrivKey()
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	privateKey := btcecPrivKey.ToECDSA()
 	publicKey := &privateKey.PublicKey // Starts with 0x04. Contains DER encoding of the public key (which is what Bitcoin and all its fork uses)
-	path := "m/44H/60H/0H/0/0"
-	return privateKey, publicKey, &path, nil
+	canonical := derivation.String()
+	return privateKey, publicKey, &canonical, nil
 }
@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mnemonic the well known development mnemonic of Hardhat and Anvil
+const mnemonic = "test test test test test test test test test test test junk"
+
+// TestDeriveKey The signing key matches the standard Ethereum path and the encryption key differs from it
+func TestDeriveKey(t *testing.T) {
+	signing, _, path, err := HDWallet(mnemonic)
+	if err != nil {
+		t.Fatalf("Failed to derive signing key: %v", err)
+	}
+	if address := crypto.PubkeyToAddress(signing.PublicKey).Hex(); address != "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266" || *path != "m/44H/60H/0H/0/0" {
+		t.Errorf("Expected the first account of the standard path, derived %s at %s", address, *path)
+	}
+	encryption, _, path, err := DeriveKey(mnemonic, "", DefaultPaths[Encryption])
+	if err != nil {
+		t.Fatalf("Failed to derive encryption key: %v", err)
+	}
+	if encryption.D.Cmp(signing.D) == 0 || *path != "m/44H/60H/1H/0/0" {
+		t.Errorf("Expected a distinct encryption key at its own account, derived at %s", *path)
+	}
+}
+
+// TestDerivationOptions Paths are validated, indexes and passphrases yield distinct keys
+func TestDerivationOptions(t *testing.T) {
+	second, _, path, err := DeriveKey(mnemonic, "", "m/44'/60'/0'/0/1")
+	if err != nil {
+		t.Fatalf("Failed to derive second account: %v", err)
+	}
+	if address := crypto.PubkeyToAddress(second.PublicKey).Hex(); address != "0x70997970C51812dc3A010C7d01b50e0d17dc79C8" || *path != "m/44H/60H/0H/0/1" {
+		t.Errorf("Expected the second account of the standard path, derived %s at %s", address, *path)
+	}
+	protected, _, _, err := DeriveKey(mnemonic, "passphrase", DefaultPaths[Signing])
+	if err != nil {
+		t.Fatalf("Failed to derive with passphrase: %v", err)
+	}
+	if crypto.PubkeyToAddress(protected.PublicKey).Hex() == "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266" {
+		t.Error("Expected the passphrase to change the derived key")
+	}
+
+	for _, path := range []string{"44H/60H", "m", "m/44H/x", "m/2147483648", "m/-1"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("Expected path %q to be rejected", path)
+		}
+	}
+	if _, _, _, err := DeriveKey("test test test", "", DefaultPaths[Signing]); err != ErrInvalidMnemonic {
+		t.Errorf("Expected an invalid mnemonic to be rejected, received %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/TheLazarusNetwork/Monitor/audit"
+	"github.com/TheLazarusNetwork/Monitor/logger"
+	"github.com/TheLazarusNetwork/Monitor/utility"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// watch streams the decrypted lines of Log events as they are logged, to the terminal or appended to a file
+//
+//	monitor watch [-endpoint wss://..|/path/geth.ipc] [-sender 0x..,0x..] [-from block] [-out watch.log] [-format text|json] [key flags]
+func watch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	endpoint := flags.String("endpoint", viper.GetString("WATCH_ENDPOINT"), "websocket or IPC endpoint of the node")
+	senders := flags.String("sender", "", "comma separated agent addresses, every sender by default")
+	from := flags.Uint64("from", 0, "block to backfill from before streaming, 0 for new events only")
+	chunk := flags.Uint64("chunk", audit.DefaultChunk, "blocks queried per request when backfilling")
+	out := flags.String("out", "", "file the lines are appended to instead of the terminal")
+	keys := keyFlags(flags)
+	format := flags.String("format", "text", "output format, text or json")
+	flags.Parse(args)
+
+	if strings.HasPrefix(*endpoint, "http") || *endpoint == "" {
+		log.Fatalf("watch needs a websocket or IPC endpoint for subscriptions, set WATCH_ENDPOINT or -endpoint (received %q)", *endpoint)
+	}
+	var addresses []common.Address
+	var err error
+	if *senders != "" {
+		addresses, err = parseSenders(*senders)
+		utility.CheckError("Error in reading senders:", err)
+	}
+	decoder, err := keys.decoder()
+	utility.CheckError("Error in loading decryption keys:", err)
+	var output io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		utility.CheckError("Error in opening output file:", err)
+		defer file.Close()
+		output = file
+	}
+	printer, err := newEntryPrinter(output, *format)
+	utility.CheckError("Error in configuring output:", err)
+
+	client, err := ethclient.Dial(*endpoint)
+	utility.CheckError("Error in connecting to watch endpoint:", err)
+	defer client.Close()
+	follower, err := audit.NewFollower(client, common.HexToAddress(viper.Get("LOGGER_CONTRACT_ADDRESS").(string)), addresses, *chunk)
+	utility.CheckError("Unable to load instance of the deployed contract:", err)
+
+	// Stop cleanly on interrupt so the output file is closed
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		stop()
+	}()
+	timestamps := audit.NewTimestamps(client)
+	log.Infof("Watching Log events of %s", viper.Get("LOGGER_CONTRACT_ADDRESS"))
+	err = follower.Run(ctx, *from, func(event *logger.LoggerLog) error {
+		entry := decoder.Decode(event)
+		entry.Time, err = timestamps.Of(ctx, entry.Block)
+		if err != nil {
+			// The node is only unreachable for now, only failing to write the output stops the watch
+			return audit.Retry(err)
+		}
+		return printer.Print(entry)
+	})
+	if err != nil && err != context.Canceled {
+		log.Fatalf("Error in watching Log events: %v", err)
+	}
+}